@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"time"
 
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/database"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/apikeys"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/auditlog"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/auth"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/example"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/roles"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/keyring"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/lockout"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/mailer"
+	appMiddleware "github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/oidc"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/revocation"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/server"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
 )
 
 func main() {
@@ -34,26 +50,178 @@ func main() {
 
 	logger.Info().Msg("Connected to database")
 
+	// Items created before they had owners need their owner backfilled
+	if err := example.MigrateOwner(db); err != nil {
+		logger.Fatal().Err(err).Msg("Database migration failed")
+	}
+
 	// Auto migrate models
-	err = db.AutoMigrate(&example.Item{})
+	err = db.AutoMigrate(
+		&example.Item{},
+		&auth.User{}, &auth.TokenFamily{}, &auth.RefreshToken{}, &auth.RecoveryCode{}, &auth.UserToken{},
+		&auth.UserIdentity{}, &auth.OIDCState{},
+		&apikeys.APIKey{},
+		&roles.Role{}, &roles.RolePermission{},
+		&revocation.RevokedToken{}, &revocation.UserRevocation{},
+		&lockout.LoginFailure{},
+		&audit.Entry{},
+	)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Database migration failed")
 	}
+
+	// Rows created before multi-tenancy belong to the default tenant
+	if err := tenant.Backfill(db, cfg.Tenant.Default, auth.User{}.TableName(), example.Item{}.TableName()); err != nil {
+		logger.Fatal().Err(err).Msg("Database migration failed")
+	}
+	if err := audit.EnsureAppendOnly(db); err != nil {
+		logger.Fatal().Err(err).Msg("Database migration failed")
+	}
+	if cfg.Tenant.RowLevelSecurity {
+		if err := tenant.EnableRowLevelSecurity(db, example.Item{}.TableName()); err != nil {
+			logger.Fatal().Err(err).Msg("Database migration failed")
+		}
+	}
 	logger.Info().Msg("Database migrated successfully")
 
+	// Audit trail of changes, also recording every request made while
+	// impersonating a user
+	auditRecorder := audit.NewPostgresRecorder(db)
+
+	// Signs the pagination cursors of list endpoints
+	cursors := listing.NewCursors(cfg.Listing.CursorSecret)
+
 	// Dependency Injection - Example Feature
-	exampleRepo := example.NewRepository(db)
-	exampleService := example.NewService(exampleRepo)
+	exampleRepo := example.NewRepository(db, cfg.Tenant.RowLevelSecurity)
+	exampleService := example.NewService(exampleRepo, cursors)
 	exampleHandler := example.NewHandler(exampleService)
 
+	// JWT keyrings for signing and verifying tokens
+	accessKeys, refreshKeys, err := keyring.Load(cfg.JWT)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load JWT keys")
+	}
+	logKeys("access", accessKeys)
+	logKeys("refresh", refreshKeys)
+
+	// Token revocation store consulted by the JWT middleware and API key authentication
+	var revocations revocation.Store = revocation.NewPostgresStore(db)
+	if cfg.JWT.RevocationStore == "memory" {
+		revocations = revocation.NewMemoryStore()
+	}
+
+	// Mail delivery for password reset and email verification
+	mailSender, err := mailer.New(cfg.Mail)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to set up mailer")
+	}
+	mail := mailer.NewQueue(mailSender, cfg.Mail.Workers, cfg.Mail.QueueSize)
+
+	// Failed login counters for brute-force protection
+	var lockoutStore lockout.Store = lockout.NewPostgresStore(db)
+	if cfg.Lockout.Store == "memory" {
+		lockoutStore = lockout.NewMemoryStore()
+	}
+	lockoutPolicy := lockout.Policy{
+		MaxFailures: cfg.Lockout.AccountMaxFailures,
+		BaseDelay:   time.Duration(cfg.Lockout.BaseDelay) * time.Second,
+		MaxDelay:    time.Duration(cfg.Lockout.MaxDelay) * time.Second,
+		Window:      time.Duration(cfg.Lockout.Window) * time.Minute,
+	}
+	ipLockoutPolicy := lockoutPolicy
+	ipLockoutPolicy.MaxFailures = cfg.Lockout.IPMaxFailures
+	limiter := lockout.New(lockoutStore, lockoutPolicy, ipLockoutPolicy)
+
+	// Throttles password reset and verification emails the same way
+	mailPolicy := lockoutPolicy
+	mailPolicy.MaxFailures = cfg.Lockout.MailAccountMaxRequests
+	ipMailPolicy := lockoutPolicy
+	ipMailPolicy.MaxFailures = cfg.Lockout.MailIPMaxRequests
+	mailLimiter := lockout.NewNamed(lockoutStore, "mail", mailPolicy, ipMailPolicy)
+
+	// Single sign-on through an OpenID Connect provider, if configured
+	var sso *oidc.Provider
+	if cfg.OIDC.Issuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		sso, err = oidc.New(ctx, cfg.OIDC, &http.Client{Timeout: 10 * time.Second})
+		cancel()
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to set up OIDC provider")
+		}
+		logger.Info().Str("issuer", sso.Issuer()).Msg("OIDC login enabled")
+	}
+
+	// Dependency Injection - Roles Feature
+	roleRepo := roles.NewRepository(db)
+	roleService := roles.NewService(roleRepo, cfg.Tenant.Default)
+	roleHandler := roles.NewHandler(roleService)
+
+	// Dependency Injection - Auth Feature
+	authRepo := auth.NewRepository(db)
+
+	authService := auth.NewService(authRepo, cfg, accessKeys, refreshKeys, revocations, mail, limiter, mailLimiter, sso, auditRecorder, roleService)
+	authHandler := auth.NewHandler(authService)
+
+	// Permanently delete items that stayed in the trash past the retention.
+	// Items belong to users of their tenant, so those are the tenants to purge.
+	if cfg.Trash.Retention > 0 {
+		go exampleService.PurgeTrash(context.Background(), authRepo.TenantIDs,
+			time.Duration(cfg.Trash.Retention)*24*time.Hour,
+			time.Duration(cfg.Trash.PurgeInterval)*time.Minute)
+	}
+
+	// Dependency Injection - API Keys Feature
+	apiKeyRepo := apikeys.NewRepository(db)
+	apiKeyService := apikeys.NewService(apiKeyRepo, revocations)
+	apiKeyHandler := apikeys.NewHandler(apiKeyService)
+
+	// Dependency Injection - Audit Log Feature
+	auditLogRepo := auditlog.NewRepository(db)
+	auditLogService := auditlog.NewService(auditLogRepo, cursors)
+	auditLogHandler := auditlog.NewHandler(auditLogService)
+
+	// Seed default roles on first start; edits made at runtime are kept
+	err = roleService.EnsureDefaults(map[string][]string{
+		"admin": {appMiddleware.PermissionWildcard},
+		"user":  {example.PermItemsRead, example.PermItemsWrite},
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to seed default roles")
+	}
+
 	// Server Setup
-	srv := server.New()
+	srv := server.New(cfg.Server, accessKeys)
 	srv.RegisterRoutes(server.RoutesConfig{
-		ExampleHandler: exampleHandler,
+		Tenant:              cfg.Tenant,
+		Audit:               auditRecorder,
+		AccessKeys:          accessKeys,
+		Revocations:         revocations,
+		APIKeyAuthenticator: apiKeyService,
+		Permissions:         roleService,
+		ExampleHandler:      exampleHandler,
+		AuthHandler:         authHandler,
+		APIKeyHandler:       apiKeyHandler,
+		RoleHandler:         roleHandler,
+		AuditLogHandler:     auditLogHandler,
 	})
 
+	// Deliver the emails still queued before exiting
+	srv.OnShutdown(mail.Close)
+
 	logger.Info().Str("port", cfg.Server.Port).Msg("Starting server")
 	if err := srv.Start(":" + cfg.Server.Port); err != nil {
 		logger.Fatal().Err(err).Msg("Server failed to start")
 	}
 }
+
+// logKeys reports which keys of a keyring are active, verify-only or retired
+func logKeys(tokenType string, keys *keyring.Keyring) {
+	for _, key := range keys.Keys() {
+		logger.Info().
+			Str("token_type", tokenType).
+			Str("kid", key.ID).
+			Str("alg", key.Method.Alg()).
+			Str("status", string(key.Status)).
+			Msg("Loaded JWT key")
+	}
+}
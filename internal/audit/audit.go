@@ -0,0 +1,91 @@
+// Package audit records who did what. Entries are written through a Recorder,
+// which takes the actor, request ID, IP and tenant from the request context so
+// that services only describe the action itself.
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry is a single record of the audit trail
+type Entry struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID       string     `gorm:"type:varchar(63);not null;index"`
+	ActorID        *uuid.UUID `gorm:"type:uuid;index"` // nil for anonymous requests
+	ImpersonatorID *uuid.UUID `gorm:"type:uuid;index"` // the admin acting as ActorID, if any
+	Action         string     `gorm:"type:varchar(64);not null;index"`
+	ResourceType   string     `gorm:"type:varchar(64);not null"`
+	ResourceID     string     `gorm:"type:varchar(255)"`
+	Changes        JSON       `gorm:"type:jsonb"` // see Diff
+	Details        JSON       `gorm:"type:jsonb"`
+	RequestID      string     `gorm:"type:varchar(64)"`
+	IP             string     `gorm:"type:varchar(45)"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime;index"`
+}
+
+// TableName returns the table name for the Entry model
+func (Entry) TableName() string {
+	return "audit_log"
+}
+
+// JSON is a JSON object stored in a jsonb column
+type JSON map[string]interface{}
+
+// Value implements driver.Valuer
+func (j JSON) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, j)
+	case string:
+		return json.Unmarshal([]byte(v), j)
+	default:
+		return errors.New("audit: unsupported JSON value")
+	}
+}
+
+// Recorder appends entries to the audit trail.
+type Recorder interface {
+	Record(ctx context.Context, entry *Entry) error
+}
+
+// Request describes the caller of the request being handled
+type Request struct {
+	ActorID        *uuid.UUID
+	ImpersonatorID *uuid.UUID
+	RequestID      string
+	IP             string
+}
+
+type contextKey struct{}
+
+// WithRequest returns a copy of ctx carrying the caller for audit entries
+func WithRequest(ctx context.Context, r Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// RequestFromContext returns the caller carried by ctx
+func RequestFromContext(ctx context.Context) (Request, bool) {
+	r, ok := ctx.Value(contextKey{}).(Request)
+	return r, ok
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Actions of entries recording a change of a resource
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore" // undoing a soft delete
+	ActionPurge   = "purge"   // permanently deleting a soft deleted resource
+)
+
+// Change builds the entry for a create, update or delete of a resource. before
+// is nil for creations and after is nil for deletions; both are usually the
+// resource's response type, so the diff shows what API clients see.
+func Change(action, resourceType, resourceID string, before, after interface{}) (*Entry, error) {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entry{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Changes:      changes,
+	}, nil
+}
+
+// Diff compares the JSON encodings of before and after and returns the fields
+// that differ as {"field": {"from": old, "to": new}}. A nil side has no fields,
+// so its counterpart's fields all appear with a missing from or to.
+func Diff(before, after interface{}) (JSON, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := JSON{}
+	for name, old := range from {
+		value, ok := to[name]
+		if !ok {
+			diff[name] = map[string]interface{}{"from": old}
+		} else if !reflect.DeepEqual(old, value) {
+			diff[name] = map[string]interface{}{"from": old, "to": value}
+		}
+	}
+	for name, value := range to {
+		if _, ok := from[name]; !ok {
+			diff[name] = map[string]interface{}{"to": value}
+		}
+	}
+	return diff, nil
+}
+
+// fields returns the top-level fields of v's JSON object encoding
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+)
+
+// PostgresRecorder writes entries to the audit_log table.
+type PostgresRecorder struct {
+	db *gorm.DB
+}
+
+func NewPostgresRecorder(db *gorm.DB) *PostgresRecorder {
+	return &PostgresRecorder{db: db}
+}
+
+// Record stores the entry, filling in the caller and tenant from ctx where
+// the entry leaves them empty
+func (r *PostgresRecorder) Record(ctx context.Context, entry *Entry) error {
+	return Insert(ctx, r.db, entry)
+}
+
+// Insert stores entries like PostgresRecorder.Record, but through db. Given
+// the transaction of the change they describe, the entries are committed or
+// rolled back with it, so the change is never stored without them.
+func Insert(ctx context.Context, db *gorm.DB, entries ...*Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for _, entry := range entries {
+		fill(ctx, entry)
+	}
+	return db.WithContext(ctx).Create(entries).Error
+}
+
+// fill sets the caller and tenant of ctx where the entry leaves them empty
+func fill(ctx context.Context, entry *Entry) {
+	if req, ok := RequestFromContext(ctx); ok {
+		if entry.ActorID == nil {
+			entry.ActorID = req.ActorID
+		}
+		if entry.ImpersonatorID == nil {
+			entry.ImpersonatorID = req.ImpersonatorID
+		}
+		if entry.RequestID == "" {
+			entry.RequestID = req.RequestID
+		}
+		if entry.IP == "" {
+			entry.IP = req.IP
+		}
+	}
+	if entry.TenantID == "" {
+		entry.TenantID, _ = tenant.FromContext(ctx)
+	}
+}
+
+// EnsureAppendOnly installs triggers rejecting updates, deletes and truncation
+// of the audit_log table, so application code cannot alter entries. The table
+// owner can still drop the triggers. Safe to run on every start.
+func EnsureAppendOnly(db *gorm.DB) error {
+	table := Entry{}.TableName()
+	statements := []string{
+		`CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql`,
+		fmt.Sprintf("DROP TRIGGER IF EXISTS audit_log_append_only ON %s", table),
+		fmt.Sprintf("CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()", table),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS audit_log_no_truncate ON %s", table),
+		fmt.Sprintf("CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON %s FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only()", table),
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("append-only %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
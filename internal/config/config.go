@@ -2,10 +2,17 @@ package config
 
 // Config holds the complete application configuration.
 type Config struct {
-	Server ServerConfig   `validate:"required"`
-	Log    LogConfig      `validate:"required"`
-	JWT    JWTConfig      `validate:"required"`
-	Db     DatabaseConfig `validate:"required"`
+	Server  ServerConfig   `validate:"required"`
+	Log     LogConfig      `validate:"required"`
+	JWT     JWTConfig      `validate:"required"`
+	Db      DatabaseConfig `validate:"required"`
+	Tenant  TenantConfig   `validate:"required"`
+	MFA     MFAConfig      `validate:"required"`
+	Mail    MailConfig     `validate:"required"`
+	Lockout LockoutConfig  `validate:"required"`
+	Listing ListingConfig  `validate:"required"`
+	Trash   TrashConfig    `validate:"required"`
+	OIDC    OIDCConfig
 }
 
 // ServerConfig defines HTTP server settings.
@@ -13,6 +20,10 @@ type ServerConfig struct {
 	Host string `validate:"required"`
 	Port string `validate:"required,numeric"`
 	Env  string `validate:"required,oneof=development production"`
+
+	// Proxies, as CIDR ranges, whose X-Forwarded-For header is trusted for the
+	// client IP. Without any, the IP of the connection is used.
+	TrustedProxies []string `validate:"dive,cidr"`
 }
 
 // LogConfig defines logging settings.
@@ -22,10 +33,103 @@ type LogConfig struct {
 
 // JWTConfig defines JWT authentication settings.
 type JWTConfig struct {
-	ATSecret    string `validate:"required"`
+	ATSecret    string `validate:"required_if=ATAlgorithm HS256"`
 	ATExpiresIn int    `validate:"min=1"`
 	RTSecret    string `validate:"required"`
 	RTExpiresIn int    `validate:"min=1"`
+
+	// Access tokens can be signed asymmetrically so other services can verify
+	// them through the JWKS endpoint without holding a shared secret.
+	ATAlgorithm      string `validate:"required,oneof=HS256 RS256 EdDSA"`
+	ATKeyID          string `validate:"required"`
+	ATPrivateKeyFile string `validate:"required_unless=ATAlgorithm HS256"` // PEM encoded
+	RTKeyID          string `validate:"required"`
+
+	// Lifetime of access tokens admins obtain to act as another user, in minutes
+	ImpersonationExpiresIn int `validate:"min=1"`
+
+	// Keyring rotation: previous keys stay verify-only until their tokens have
+	// expired. Entries are "kid:alg:material", where material is the secret for
+	// HS256 and a PEM file path for RS256/EdDSA. Retired kids are rejected.
+	ATVerifyKeys  []string
+	RTVerifyKeys  []string
+	RetiredKeyIDs []string
+
+	RevocationStore string `validate:"required,oneof=postgres memory"`
+}
+
+// TenantConfig defines how the tenant of a request is resolved.
+type TenantConfig struct {
+	Default    string `validate:"required,max=63"` // used when a request names no tenant
+	Header     string `validate:"required"`
+	BaseDomain string // subdomains of it select the tenant, e.g. acme.example.com
+
+	// Enforce isolation in Postgres as well, with row-level security policies
+	RowLevelSecurity bool
+}
+
+// MFAConfig defines TOTP multi-factor authentication settings.
+type MFAConfig struct {
+	Issuer         string `validate:"required"` // shown in authenticator apps
+	TokenExpiresIn int    `validate:"min=1"`    // in minutes, for the mfa_pending token
+}
+
+// MailConfig defines how emails are delivered.
+type MailConfig struct {
+	Driver      string `validate:"required,oneof=smtp file log"`
+	From        string `validate:"required"`
+	LinkBaseURL string `validate:"required,url"` // frontend handling reset and verification links
+
+	SMTPHost     string `validate:"required_if=Driver smtp"`
+	SMTPPort     int    `validate:"min=1,max=65535"`
+	SMTPUsername string
+	SMTPPassword string
+
+	FileDir string `validate:"required_if=Driver file"`
+
+	// Emails are delivered in the background by Workers, with up to QueueSize
+	// more waiting; further emails are dropped until the queue has room
+	Workers   int `validate:"min=1"`
+	QueueSize int `validate:"min=1"`
+}
+
+// LockoutConfig defines brute-force protection for logins. Once an account or
+// IP address reaches its failure limit it is locked out for BaseDelay, doubling
+// with each further failure up to MaxDelay.
+type LockoutConfig struct {
+	Store              string `validate:"required,oneof=postgres memory"`
+	AccountMaxFailures int    `validate:"min=1"`
+	IPMaxFailures      int    `validate:"min=1"`
+	BaseDelay          int    `validate:"min=1"` // in seconds
+	MaxDelay           int    `validate:"min=1"` // in seconds
+	Window             int    `validate:"min=1"` // in minutes without failures before they are forgotten
+
+	// Password reset and verification emails an account or IP address may
+	// request before being locked out with the same delays
+	MailAccountMaxRequests int `validate:"min=1"`
+	MailIPMaxRequests      int `validate:"min=1"`
+}
+
+// OIDCConfig defines single sign-on through an OpenID Connect provider.
+// It is disabled while Issuer is empty.
+type OIDCConfig struct {
+	Issuer       string `validate:"omitempty,url"`
+	ClientID     string `validate:"required_with=Issuer"`
+	ClientSecret string // empty for public clients, which rely on PKCE alone
+	RedirectURL  string `validate:"required_with=Issuer,omitempty,url"` // frontend page receiving the code
+	Scopes       []string
+}
+
+// ListingConfig defines settings of list endpoints.
+type ListingConfig struct {
+	CursorSecret string // signs pagination cursors, derived from JWT.RTSecret if empty
+}
+
+// TrashConfig defines how long soft deleted items are kept before a
+// background job purges them.
+type TrashConfig struct {
+	Retention     int `validate:"min=0"` // in days, 0 keeps deleted items forever
+	PurgeInterval int `validate:"min=1"` // in minutes between purge runs
 }
 
 // DatabaseConfig defines PostgreSQL connection settings.
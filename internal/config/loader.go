@@ -1,7 +1,12 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"log"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
@@ -23,6 +28,8 @@ func Load() (*Config, error) {
 			Host: viper.GetString("SERVER_HOST"),
 			Port: viper.GetString("SERVER_PORT"),
 			Env:  viper.GetString("SERVER_ENV"),
+
+			TrustedProxies: getList("SERVER_TRUSTED_PROXIES"),
 		},
 		Log: LogConfig{
 			Level: viper.GetString("LOG_LEVEL"),
@@ -32,6 +39,19 @@ func Load() (*Config, error) {
 			ATExpiresIn: viper.GetInt("JWT_AT_EXPIRES_IN"),
 			RTSecret:    viper.GetString("JWT_RT_SECRET"),
 			RTExpiresIn: viper.GetInt("JWT_RT_EXPIRES_IN"),
+
+			ATAlgorithm:      getStringWithDefault("JWT_AT_ALGORITHM", "HS256"),
+			ATKeyID:          getStringWithDefault("JWT_AT_KEY_ID", "default"),
+			ATPrivateKeyFile: viper.GetString("JWT_AT_PRIVATE_KEY_FILE"),
+			RTKeyID:          getStringWithDefault("JWT_RT_KEY_ID", "default"),
+
+			ImpersonationExpiresIn: getIntWithDefault("JWT_IMPERSONATION_EXPIRES_IN", 10),
+
+			ATVerifyKeys:  getList("JWT_AT_VERIFY_KEYS"),
+			RTVerifyKeys:  getList("JWT_RT_VERIFY_KEYS"),
+			RetiredKeyIDs: getList("JWT_RETIRED_KEY_IDS"),
+
+			RevocationStore: getStringWithDefault("JWT_REVOCATION_STORE", "postgres"),
 		},
 		Db: DatabaseConfig{
 			Host:            viper.GetString("DB_HOST"),
@@ -44,16 +64,97 @@ func Load() (*Config, error) {
 			MaxIdleConns:    getIntWithDefault("DB_MAX_IDLE_CONNS", 5),
 			ConnMaxLifetime: getIntWithDefault("DB_CONN_MAX_LIFETIME", 60),
 		},
+		Tenant: TenantConfig{
+			Default:          getStringWithDefault("TENANT_DEFAULT", "default"),
+			Header:           getStringWithDefault("TENANT_HEADER", "X-Tenant-ID"),
+			BaseDomain:       viper.GetString("TENANT_BASE_DOMAIN"),
+			RowLevelSecurity: viper.GetBool("TENANT_ROW_LEVEL_SECURITY"),
+		},
+		MFA: MFAConfig{
+			Issuer:         getStringWithDefault("MFA_ISSUER", "Go Backend Template"),
+			TokenExpiresIn: getIntWithDefault("MFA_TOKEN_EXPIRES_IN", 5),
+		},
+		Mail: MailConfig{
+			Driver:      getStringWithDefault("MAIL_DRIVER", "log"),
+			From:        getStringWithDefault("MAIL_FROM", "no-reply@localhost"),
+			LinkBaseURL: getStringWithDefault("MAIL_LINK_BASE_URL", "http://localhost:3000"),
+
+			SMTPHost:     viper.GetString("MAIL_SMTP_HOST"),
+			SMTPPort:     getIntWithDefault("MAIL_SMTP_PORT", 587),
+			SMTPUsername: viper.GetString("MAIL_SMTP_USERNAME"),
+			SMTPPassword: viper.GetString("MAIL_SMTP_PASSWORD"),
+
+			FileDir: getStringWithDefault("MAIL_FILE_DIR", "tmp/mail"),
+
+			Workers:   getIntWithDefault("MAIL_WORKERS", 4),
+			QueueSize: getIntWithDefault("MAIL_QUEUE_SIZE", 1000),
+		},
+		Lockout: LockoutConfig{
+			Store:              getStringWithDefault("LOCKOUT_STORE", "postgres"),
+			AccountMaxFailures: getIntWithDefault("LOCKOUT_ACCOUNT_MAX_FAILURES", 5),
+			IPMaxFailures:      getIntWithDefault("LOCKOUT_IP_MAX_FAILURES", 20),
+			BaseDelay:          getIntWithDefault("LOCKOUT_BASE_DELAY", 30),
+			MaxDelay:           getIntWithDefault("LOCKOUT_MAX_DELAY", 3600),
+			Window:             getIntWithDefault("LOCKOUT_WINDOW", 1440),
+
+			MailAccountMaxRequests: getIntWithDefault("LOCKOUT_MAIL_ACCOUNT_MAX_REQUESTS", 3),
+			MailIPMaxRequests:      getIntWithDefault("LOCKOUT_MAIL_IP_MAX_REQUESTS", 10),
+		},
+		Listing: ListingConfig{
+			CursorSecret: viper.GetString("LISTING_CURSOR_SECRET"),
+		},
+		Trash: TrashConfig{
+			Retention:     getIntWithDefault("TRASH_RETENTION", 30),
+			PurgeInterval: getIntWithDefault("TRASH_PURGE_INTERVAL", 60),
+		},
+		OIDC: OIDCConfig{
+			Issuer:       viper.GetString("OIDC_ISSUER"),
+			ClientID:     viper.GetString("OIDC_CLIENT_ID"),
+			ClientSecret: viper.GetString("OIDC_CLIENT_SECRET"),
+			RedirectURL:  viper.GetString("OIDC_REDIRECT_URL"),
+			Scopes:       getList("OIDC_SCOPES"),
+		},
+	}
+
+	// The refresh token secret is always set, so cursors can be signed with a
+	// key derived from it. Deriving keeps a leaked cursor key from revealing
+	// the secret itself.
+	if cfg.Listing.CursorSecret == "" {
+		cfg.Listing.CursorSecret = deriveSecret(cfg.JWT.RTSecret, "listing cursors")
 	}
 
 	validate := validator.New()
 	if err := validate.Struct(cfg); err != nil {
 		return nil, ParseValidationErrors(err)
 	}
+	if err := validateProduction(cfg); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+// validateProduction rejects settings that are only safe in development
+func validateProduction(cfg *Config) error {
+	if Env(cfg.Server.Env) != Production {
+		return nil
+	}
+
+	// The log and file drivers leave password reset links readable to anyone
+	// with access to the logs or disk
+	if cfg.Mail.Driver != "smtp" {
+		return errors.New("configuration validation failed: MAIL_DRIVER (must be smtp when SERVER_ENV=production)")
+	}
+	return nil
+}
+
+// deriveSecret returns a secret for the given purpose derived from another one
+func deriveSecret(secret, purpose string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // getIntWithDefault returns the int value for the key or the default if not set
 func getIntWithDefault(key string, defaultValue int) int {
 	if viper.IsSet(key) {
@@ -61,3 +162,22 @@ func getIntWithDefault(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getStringWithDefault returns the string value for the key or the default if not set
+func getStringWithDefault(key, defaultValue string) string {
+	if viper.IsSet(key) {
+		return viper.GetString(key)
+	}
+	return defaultValue
+}
+
+// getList returns the comma separated values for the key, skipping empty entries
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(viper.GetString(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -68,6 +68,10 @@ func getValidationReason(e validator.FieldError) string {
 	switch e.Tag() {
 	case "required":
 		return "is required but not set"
+	case "required_if":
+		return fmt.Sprintf("is required when %s", conditionParam(e))
+	case "required_unless":
+		return fmt.Sprintf("is required unless %s", conditionParam(e))
 	case "numeric":
 		return "must be a number"
 	case "oneof":
@@ -80,6 +84,8 @@ func getValidationReason(e validator.FieldError) string {
 		return "must be a valid email address"
 	case "url":
 		return "must be a valid URL"
+	case "cidr":
+		return "must be a CIDR range such as 10.0.0.0/8"
 	case "len":
 		return fmt.Sprintf("must be exactly %s characters", e.Param())
 	case "gt":
@@ -94,3 +100,19 @@ func getValidationReason(e validator.FieldError) string {
 		return fmt.Sprintf("failed validation '%s'", e.Tag())
 	}
 }
+
+// conditionParam renders a "Field value" param of a conditional validation tag
+// as "ENV_NAME=value", resolving Field relative to the failing field's struct.
+func conditionParam(e validator.FieldError) string {
+	field, value, _ := strings.Cut(e.Param(), " ")
+
+	parts := strings.Split(strings.TrimPrefix(e.StructNamespace(), "Config."), ".")
+	parts[len(parts)-1] = field
+
+	var snakeParts []string
+	for _, part := range parts {
+		snakeParts = append(snakeParts, toSnakeCase(part))
+	}
+
+	return fmt.Sprintf("%s=%s", strings.ToUpper(strings.Join(snakeParts, "_")), value)
+}
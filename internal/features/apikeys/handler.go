@@ -0,0 +1,172 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	apperrors "github.com/SuperIntelligence-Labs/go-backend-template/internal/errors"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Create handles POST /api-keys
+func (h *Handler) Create(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if details := unauthorizedScopes(c, req.Scopes); len(details) > 0 {
+		return response.ErrValidationFailed(details)
+	}
+
+	key, err := h.service.Create(claims.UserID, req)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrValidation) {
+			return response.ErrValidationFailed([]response.ValidationError{
+				{Field: "expires_at", Message: "Must be in the future"},
+			})
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.Created(c, "API key created successfully, store it now as it will not be shown again", key)
+}
+
+// GetByID handles GET /api-keys/:id
+func (h *Handler) GetByID(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid API key ID", nil)
+	}
+
+	key, err := h.service.GetByID(claims.UserID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("API key not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "API key retrieved successfully", key)
+}
+
+// GetAll handles GET /api-keys
+func (h *Handler) GetAll(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.service.GetAll(claims.UserID)
+	if err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "API keys retrieved successfully", keys)
+}
+
+// Update handles PUT /api-keys/:id
+func (h *Handler) Update(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid API key ID", nil)
+	}
+
+	var req UpdateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if req.Scopes != nil {
+		if details := unauthorizedScopes(c, *req.Scopes); len(details) > 0 {
+			return response.ErrValidationFailed(details)
+		}
+	}
+
+	key, err := h.service.Update(claims.UserID, id, req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("API key not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "API key updated successfully", key)
+}
+
+// Delete handles DELETE /api-keys/:id
+func (h *Handler) Delete(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid API key ID", nil)
+	}
+
+	rowsAffected, err := h.service.Delete(claims.UserID, id)
+	if err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return response.ErrNotFound("API key not found")
+	}
+
+	return response.NoContent(c)
+}
+
+// unauthorizedScopes reports scopes the caller does not hold, so a key can
+// never grant more than its owner has
+func unauthorizedScopes(c echo.Context, scopes []string) []response.ValidationError {
+	var details []response.ValidationError
+	for i, scope := range scopes {
+		if !middleware.HasPermission(c, scope) {
+			details = append(details, response.ValidationError{
+				Field:   fmt.Sprintf("scopes[%d]", i),
+				Message: "You do not have the " + scope + " permission",
+			})
+		}
+	}
+	return details
+}
@@ -0,0 +1,78 @@
+package apikeys
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution limits how often last_used_at is written for a busy key
+const lastUsedResolution = time.Minute
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Create(key *APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// FindByID returns the key only if it belongs to the user
+func (r *Repository) FindByID(userID, id uuid.UUID) (*APIKey, error) {
+	var key APIKey
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *Repository) FindAllByUser(userID uuid.UUID) ([]APIKey, error) {
+	var keys []APIKey
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// FindByPrefix loads a key together with its owner for authentication
+func (r *Repository) FindByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	var key APIKey
+	err := r.db.WithContext(ctx).Preload("User").Where("prefix = ?", prefix).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// UpdateFields performs an atomic update of specific fields of the user's key
+func (r *Repository) UpdateFields(userID, id uuid.UUID, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil // No fields to update
+	}
+	result := r.db.Model(&APIKey{}).Where("id = ? AND user_id = ?", id, userID).Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed records that the key was used, at most once per lastUsedResolution
+func (r *Repository) TouchLastUsed(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-lastUsedResolution)).
+		UpdateColumn("last_used_at", now).Error
+}
+
+func (r *Repository) Delete(userID, id uuid.UUID) (int64, error) {
+	result := r.db.Delete(&APIKey{}, "id = ? AND user_id = ?", id, userID)
+	return result.RowsAffected, result.Error
+}
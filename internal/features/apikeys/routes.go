@@ -0,0 +1,21 @@
+package apikeys
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+)
+
+// RegisterRoutes registers all API key management routes. Keys are managed
+// with a user's access token, never with another API key. Impersonation
+// tokens can list keys but not change them, since a key minted with one
+// would outlive the impersonation and act without its act claim.
+func RegisterRoutes(g *echo.Group, h *Handler, requireAuth echo.MiddlewareFunc) {
+	g.Use(requireAuth)
+
+	g.POST("", h.Create, middleware.RejectImpersonation)
+	g.GET("", h.GetAll)
+	g.GET("/:id", h.GetByID)
+	g.PUT("/:id", h.Update, middleware.RejectImpersonation)
+	g.DELETE("/:id", h.Delete, middleware.RejectImpersonation)
+}
@@ -0,0 +1,95 @@
+package auditlog
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// List handles GET /audit
+func (h *Handler) List(c echo.Context) error {
+	req, details := parseListQuery(c)
+	if len(details) > 0 {
+		return response.ErrValidationFailed(details)
+	}
+
+	entries, total, neighbours, err := h.service.List(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, listing.ErrInvalidCursor) {
+			return response.ErrValidationFailed([]response.ValidationError{listing.InvalidCursor})
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.Paginated(c, "Audit entries retrieved successfully", entries, req.Meta(total, neighbours))
+}
+
+// parseListQuery reads the filter and pagination query parameters of
+// GET /audit, reporting every invalid parameter by name
+func parseListQuery(c echo.Context) (ListRequest, []response.ValidationError) {
+	var req ListRequest
+	var details []response.ValidationError
+	invalid := func(field, message string) {
+		details = append(details, response.ValidationError{Field: field, Message: message})
+	}
+
+	req.PageRequest, details = listing.ParsePage(c, 50, 200)
+
+	id := func(field string) *uuid.UUID {
+		value := c.QueryParam(field)
+		if value == "" {
+			return nil
+		}
+		id, err := uuid.Parse(value)
+		if err != nil {
+			invalid(field, "Must be a valid UUID")
+			return nil
+		}
+		return &id
+	}
+	req.Filter.ActorID = id("actor_id")
+	req.Filter.ImpersonatorID = id("impersonator_id")
+
+	text := func(field string, max int) string {
+		value := c.QueryParam(field)
+		if len(value) > max {
+			invalid(field, "Must be at most "+strconv.Itoa(max)+" characters/items")
+		}
+		return value
+	}
+	req.Filter.Action = text("action", 64)
+	req.Filter.ResourceType = text("resource_type", 64)
+	req.Filter.ResourceID = text("resource_id", 255)
+	req.Filter.RequestID = text("request_id", 64)
+
+	timestamp := func(field string) *time.Time {
+		value := c.QueryParam(field)
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			invalid(field, "Must be an RFC 3339 timestamp, e.g. 2024-01-31T15:04:05Z")
+			return nil
+		}
+		return &t
+	}
+	req.Filter.From = timestamp("from")
+	req.Filter.To = timestamp("to")
+
+	return req, details
+}
@@ -0,0 +1,79 @@
+package auditlog
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+)
+
+// Filter narrows down the listed entries; zero values match everything
+type Filter struct {
+	ActorID        *uuid.UUID
+	ImpersonatorID *uuid.UUID
+	Action         string
+	ResourceType   string
+	ResourceID     string
+	RequestID      string
+	From           *time.Time
+	To             *time.Time
+}
+
+// scope applies the filter to a query
+func (f Filter) scope(db *gorm.DB) *gorm.DB {
+	if f.ActorID != nil {
+		db = db.Where("actor_id = ?", *f.ActorID)
+	}
+	if f.ImpersonatorID != nil {
+		db = db.Where("impersonator_id = ?", *f.ImpersonatorID)
+	}
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.ResourceType != "" {
+		db = db.Where("resource_type = ?", f.ResourceType)
+	}
+	if f.ResourceID != "" {
+		db = db.Where("resource_id = ?", f.ResourceID)
+	}
+	if f.RequestID != "" {
+		db = db.Where("request_id = ?", f.RequestID)
+	}
+	if f.From != nil {
+		db = db.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("created_at < ?", *f.To)
+	}
+	return db
+}
+
+// Repository reads the audit trail of the tenant of the context it is given.
+// Entries are written through an audit.Recorder, never here.
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindAll returns the rows of the page and the number of entries matching
+// the filter
+func (r *Repository) FindAll(ctx context.Context, filter Filter, page listing.Page) ([]audit.Entry, int64, error) {
+	filtered := r.db.WithContext(ctx).Scopes(tenant.Scope, filter.scope).Session(&gorm.Session{})
+
+	var total int64
+	if err := filtered.Model(&audit.Entry{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []audit.Entry
+	err := filtered.Scopes(page.Scope).Find(&entries).Error
+	return entries, total, err
+}
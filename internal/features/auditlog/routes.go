@@ -0,0 +1,17 @@
+package auditlog
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+)
+
+// Permissions required by the audit log routes
+const (
+	PermAuditRead = "audit:read"
+)
+
+// RegisterRoutes registers the audit log routes
+func RegisterRoutes(g *echo.Group, h *Handler) {
+	g.GET("", h.List, middleware.RequirePermission(PermAuditRead))
+}
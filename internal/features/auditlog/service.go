@@ -0,0 +1,91 @@
+package auditlog
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+)
+
+type Service struct {
+	repo    *Repository
+	cursors *listing.Cursors
+}
+
+func NewService(repo *Repository, cursors *listing.Cursors) *Service {
+	return &Service{repo: repo, cursors: cursors}
+}
+
+// newestFirst is the order of the audit trail
+var newestFirst = []listing.Sort{{Field: "created_at", Column: "created_at", Desc: true}}
+
+// ListRequest represents the filters and page of an audit entry list
+type ListRequest struct {
+	Filter Filter
+	listing.PageRequest
+}
+
+// EntryResponse represents an audit entry
+type EntryResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	ActorID        *uuid.UUID `json:"actor_id"`
+	ImpersonatorID *uuid.UUID `json:"impersonator_id,omitempty"`
+	Action         string     `json:"action"`
+	ResourceType   string     `json:"resource_type"`
+	ResourceID     string     `json:"resource_id,omitempty"`
+	Changes        audit.JSON `json:"changes,omitempty"`
+	Details        audit.JSON `json:"details,omitempty"`
+	RequestID      string     `json:"request_id,omitempty"`
+	IP             string     `json:"ip,omitempty"`
+	CreatedAt      string     `json:"created_at"`
+}
+
+// List returns the entries matching the request, newest first, their total
+// number and the cursors of the neighbouring pages
+func (s *Service) List(ctx context.Context, req ListRequest) ([]EntryResponse, int64, listing.Neighbours, error) {
+	page := listing.Page{Sorts: newestFirst, Tiebreak: "id", Limit: req.Limit, Offset: req.Offset}
+	if req.Cursor != "" {
+		cursor, err := s.cursors.Decode(req.Cursor, page.Sorts)
+		if err != nil {
+			return nil, 0, listing.Neighbours{}, err
+		}
+		page.Cursor = cursor
+	}
+
+	entries, total, err := s.repo.FindAll(ctx, req.Filter, page)
+	if err != nil {
+		return nil, 0, listing.Neighbours{}, err
+	}
+
+	entries, neighbours, err := listing.Result(page, s.cursors, entries, func(entry audit.Entry) ([]interface{}, string) {
+		return []interface{}{entry.CreatedAt}, entry.ID.String()
+	})
+	if err != nil {
+		return nil, 0, listing.Neighbours{}, err
+	}
+
+	responses := make([]EntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = *toResponse(&entry)
+	}
+
+	return responses, total, neighbours, nil
+}
+
+func toResponse(entry *audit.Entry) *EntryResponse {
+	return &EntryResponse{
+		ID:             entry.ID,
+		ActorID:        entry.ActorID,
+		ImpersonatorID: entry.ImpersonatorID,
+		Action:         entry.Action,
+		ResourceType:   entry.ResourceType,
+		ResourceID:     entry.ResourceID,
+		Changes:        entry.Changes,
+		Details:        entry.Details,
+		RequestID:      entry.RequestID,
+		IP:             entry.IP,
+		CreatedAt:      entry.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
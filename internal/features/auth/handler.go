@@ -0,0 +1,552 @@
+package auth
+
+import (
+	"errors"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	apperrors "github.com/SuperIntelligence-Labs/go-backend-template/internal/errors"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/lockout"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Register handles POST /auth/register
+func (h *Handler) Register(c echo.Context) error {
+	var req RegisterRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	user, err := h.service.Register(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrConflict) {
+			return response.ErrConflict("Username or email already in use")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.Created(c, "User registered successfully", user)
+}
+
+// Login handles POST /auth/login
+func (h *Handler) Login(c echo.Context) error {
+	var req LoginRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	tokens, challenge, err := h.service.Login(c.Request().Context(), req, clientFrom(c))
+	if err != nil {
+		if locked := lockedOut(c, err); locked != nil {
+			return locked
+		}
+		if errors.Is(err, apperrors.ErrUnauthorized) {
+			return response.ErrUnauthorized("Invalid email or password")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	if challenge != nil {
+		return response.OK(c, "MFA code required", challenge)
+	}
+
+	return response.OK(c, "Logged in successfully", tokens)
+}
+
+// OIDCAuthorize handles GET /auth/oidc/authorize
+func (h *Handler) OIDCAuthorize(c echo.Context) error {
+	authorization, err := h.service.StartOIDCLogin(c.Request().Context())
+	if err != nil {
+		if errors.Is(err, errOIDCDisabled) {
+			return response.ErrNotFound("Single sign-on is not configured")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Authorization URL created", authorization)
+}
+
+// OIDCCallback handles POST /auth/oidc/callback
+func (h *Handler) OIDCCallback(c echo.Context) error {
+	var req OIDCCallbackRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	tokens, challenge, err := h.service.CompleteOIDCLogin(c.Request().Context(), req, clientFrom(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, errOIDCDisabled):
+			return response.ErrNotFound("Single sign-on is not configured")
+		case errors.Is(err, apperrors.ErrUnauthorized):
+			return response.ErrUnauthorized("Single sign-on failed, please try again")
+		case errors.Is(err, errOIDCEmailUnverified):
+			return response.ErrForbidden("The identity provider did not confirm your email address")
+		case errors.Is(err, apperrors.ErrConflict):
+			return response.ErrConflict("An account with this email already exists and cannot be linked")
+		default:
+			return response.ErrInternalError(err)
+		}
+	}
+
+	if challenge != nil {
+		return response.OK(c, "MFA code required", challenge)
+	}
+
+	return response.OK(c, "Logged in successfully", tokens)
+}
+
+// Refresh handles POST /auth/refresh
+func (h *Handler) Refresh(c echo.Context) error {
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	tokens, err := h.service.Refresh(c.Request().Context(), req, clientFrom(c))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrUnauthorized) {
+			return response.ErrUnauthorized("Invalid or expired refresh token")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Tokens refreshed successfully", tokens)
+}
+
+// Logout handles POST /auth/logout
+func (h *Handler) Logout(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	var req RefreshRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if err := h.service.Logout(c.Request().Context(), claims, req); err != nil {
+		if errors.Is(err, apperrors.ErrUnauthorized) {
+			return response.ErrUnauthorized("Invalid or expired refresh token")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// ListSessions handles GET /auth/sessions
+func (h *Handler) ListSessions(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := h.service.ListSessions(claims)
+	if err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Sessions retrieved successfully", sessions)
+}
+
+// RevokeSession handles DELETE /auth/sessions/:id
+func (h *Handler) RevokeSession(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid session ID", nil)
+	}
+
+	if err := h.service.RevokeSession(c.Request().Context(), claims.UserID, id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("Session not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// RevokeOtherSessions handles DELETE /auth/sessions/others
+func (h *Handler) RevokeOtherSessions(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.RevokeOtherSessions(c.Request().Context(), claims); err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// RevokeUser handles POST /auth/users/:id/revoke
+func (h *Handler) RevokeUser(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid user ID", nil)
+	}
+
+	if err := h.service.RevokeUser(c.Request().Context(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("User not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// Impersonate handles POST /auth/users/:id/impersonate
+func (h *Handler) Impersonate(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid user ID", nil)
+	}
+
+	holds := func(permission string) bool {
+		return middleware.HasPermission(c, permission)
+	}
+
+	impersonation, err := h.service.Impersonate(c.Request().Context(), claims, id, holds)
+	if err != nil {
+		switch {
+		case errors.Is(err, errNestedImpersonation):
+			return response.ErrForbidden("Not allowed while impersonating a user")
+		case errors.Is(err, errImpersonationEscalation):
+			return response.ErrForbidden("The user has permissions you do not have")
+		case errors.Is(err, errSelfImpersonation):
+			return response.ErrBadRequest("You cannot impersonate yourself", nil)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return response.ErrNotFound("User not found")
+		default:
+			return response.ErrInternalError(err)
+		}
+	}
+
+	return response.OK(c, "Impersonation token issued", impersonation)
+}
+
+// Unlock handles POST /auth/users/:id/unlock
+func (h *Handler) Unlock(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid user ID", nil)
+	}
+
+	if err := h.service.Unlock(c.Request().Context(), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("User not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// UnlockIP handles POST /auth/ips/:ip/unlock
+func (h *Handler) UnlockIP(c echo.Context) error {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		return response.ErrBadRequest("Invalid IP address", nil)
+	}
+
+	if err := h.service.UnlockIP(c.Request().Context(), ip.String()); err != nil {
+		if errors.Is(err, errSharedLockout) {
+			return response.ErrForbidden("IP lockouts can only be lifted from the default tenant")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// ForgotPassword handles POST /auth/password/forgot
+func (h *Handler) ForgotPassword(c echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if err := h.service.ForgotPassword(c.Request().Context(), req, clientFrom(c)); err != nil {
+		if locked := lockedOut(c, err); locked != nil {
+			return locked
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.Accepted[any](c, "If the email belongs to an account, a reset link has been sent", nil)
+}
+
+// ResetPassword handles POST /auth/password/reset
+func (h *Handler) ResetPassword(c echo.Context) error {
+	var req ResetPasswordRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if err := h.service.ResetPassword(c.Request().Context(), req); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrBadRequest("Invalid or expired token", nil)
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK[any](c, "Password reset successfully", nil)
+}
+
+// RequestEmailVerification handles POST /auth/email/verification
+func (h *Handler) RequestEmailVerification(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.RequestEmailVerification(c.Request().Context(), claims.UserID, clientFrom(c)); err != nil {
+		if locked := lockedOut(c, err); locked != nil {
+			return locked
+		}
+		if errors.Is(err, apperrors.ErrConflict) {
+			return response.ErrConflict("Email is already verified")
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("User not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.Accepted[any](c, "Verification email sent", nil)
+}
+
+// VerifyEmail handles POST /auth/email/verify
+func (h *Handler) VerifyEmail(c echo.Context) error {
+	var req VerifyEmailRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if err := h.service.VerifyEmail(req); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrBadRequest("Invalid or expired token", nil)
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK[any](c, "Email verified successfully", nil)
+}
+
+// VerifyMFA handles POST /auth/mfa/verify
+func (h *Handler) VerifyMFA(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	req, err := bindMFACode(c)
+	if err != nil {
+		return err
+	}
+
+	tokens, err := h.service.VerifyMFA(c.Request().Context(), claims, req, clientFrom(c))
+	if err != nil {
+		if locked := lockedOut(c, err); locked != nil {
+			return locked
+		}
+		if errors.Is(err, apperrors.ErrUnauthorized) {
+			return response.ErrUnauthorized("Invalid MFA code")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Logged in successfully", tokens)
+}
+
+// EnrollMFA handles POST /auth/mfa/enroll
+func (h *Handler) EnrollMFA(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	enrollment, err := h.service.EnrollMFA(claims.UserID)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.OK(c, "MFA enrollment started", enrollment)
+}
+
+// EnableMFA handles POST /auth/mfa/enable
+func (h *Handler) EnableMFA(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	req, err := bindMFACode(c)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.service.EnableMFA(claims.UserID, req)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.OK(c, "MFA enabled successfully", codes)
+}
+
+// DisableMFA handles POST /auth/mfa/disable
+func (h *Handler) DisableMFA(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	req, err := bindMFACode(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DisableMFA(claims.UserID, req); err != nil {
+		return mfaError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// RegenerateRecoveryCodes handles POST /auth/mfa/recovery-codes
+func (h *Handler) RegenerateRecoveryCodes(c echo.Context) error {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return err
+	}
+
+	req, err := bindMFACode(c)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(claims.UserID, req)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return response.OK(c, "Recovery codes regenerated successfully", codes)
+}
+
+func bindMFACode(c echo.Context) (MFACodeRequest, error) {
+	var req MFACodeRequest
+	if err := c.Bind(&req); err != nil {
+		return req, response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return req, response.ErrValidationFailed(details)
+	}
+
+	return req, nil
+}
+
+// mfaError maps errors of the MFA management endpoints to responses
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, apperrors.ErrUnauthorized):
+		return response.ErrUnauthorized("Invalid MFA code")
+	case errors.Is(err, apperrors.ErrConflict):
+		return response.ErrConflict("MFA is already enabled")
+	case errors.Is(err, errMFANotEnrolled):
+		return response.ErrBadRequest("Start MFA enrollment first", nil)
+	case errors.Is(err, errMFANotEnabled):
+		return response.ErrBadRequest("MFA is not enabled", nil)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return response.ErrNotFound("User not found")
+	default:
+		return response.ErrInternalError(err)
+	}
+}
+
+// lockedOut turns a lockout into a 429 response with a Retry-After header.
+// It returns nil for any other error.
+func lockedOut(c echo.Context, err error) error {
+	var locked *lockout.LockedError
+	if !errors.As(err, &locked) {
+		return nil
+	}
+
+	seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+	c.Response().Header().Set("Retry-After", strconv.Itoa(seconds))
+	return response.ErrTooManyRequests("Too many attempts, try again later")
+}
+
+// clientFrom describes the device making the request
+func clientFrom(c echo.Context) Client {
+	return Client{IP: c.RealIP(), UserAgent: c.Request().UserAgent()}
+}
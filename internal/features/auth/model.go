@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User represents an account that can authenticate against the API
+type User struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	Username     string    `gorm:"type:varchar(50);uniqueIndex;not null"`
+	Email        string    `gorm:"type:varchar(255);uniqueIndex;not null"`
+	PasswordHash string    `gorm:"type:varchar(255);not null"`
+	Role         string    `gorm:"type:varchar(50);not null;default:user"`
+	TenantID     string    `gorm:"type:varchar(63);not null;default:'';index"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+
+	EmailVerifiedAt *time.Time // set once the user followed a verification link
+
+	// TOTP multi-factor authentication. The secret is stored on enrollment and
+	// MFA is only enforced once a first code has confirmed it (MFAEnabledAt).
+	MFASecret    string `gorm:"type:varchar(64)"`
+	MFAEnabledAt *time.Time
+	MFALastStep  int64 `gorm:"not null;default:0"` // last accepted time step, prevents code reuse
+}
+
+// MFAEnabled reports whether logging in requires a second factor
+func (u *User) MFAEnabled() bool {
+	return u.MFAEnabledAt != nil
+}
+
+// TableName returns the table name for the User model
+func (User) TableName() string {
+	return "users"
+}
+
+// TokenFamily groups every refresh token descended from a single login and is
+// what users see as a session. Revoking the family invalidates all of its
+// refresh tokens at once.
+type TokenFamily struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	RevokedAt *time.Time `gorm:"index"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+
+	// Device details shown in the session list, updated on every refresh
+	UserAgent  string `gorm:"type:varchar(512)"`
+	IP         string `gorm:"type:varchar(45)"`
+	LastUsedAt *time.Time
+}
+
+// TableName returns the table name for the TokenFamily model
+func (TokenFamily) TableName() string {
+	return "token_families"
+}
+
+// RefreshToken records an issued refresh token, keyed by its jti claim
+type RefreshToken struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primaryKey"`
+	FamilyID  uuid.UUID   `gorm:"type:uuid;not null;index"`
+	Family    TokenFamily `gorm:"foreignKey:FamilyID;constraint:OnDelete:CASCADE"`
+	UserID    uuid.UUID   `gorm:"type:uuid;not null;index"`
+	ExpiresAt time.Time   `gorm:"not null"`
+	RotatedAt *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for the RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code when the
+// authenticator is unavailable. Only its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	CodeHash  string    `gorm:"type:char(64);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for the RecoveryCode model
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// Purposes of a UserToken
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+// UserToken is a single-use, expiring token sent to a user by email. Only its
+// SHA-256 hash is stored.
+type UserToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Purpose   string    `gorm:"type:varchar(32);not null"`
+	TokenHash string    `gorm:"type:char(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for the UserToken model
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
+
+// UserIdentity links a user to their account at an OpenID Connect provider,
+// identified by the provider's issuer and subject
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Issuer    string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Subject   string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_identities_issuer_subject"`
+	Email     string    `gorm:"type:varchar(255)"` // as reported by the provider at link time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for the UserIdentity model
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCState holds what a pending OpenID Connect login needs to complete: the
+// nonce expected in the ID token and the PKCE code verifier. It is keyed by
+// the SHA-256 hash of the state parameter and can be used once.
+type OIDCState struct {
+	StateHash    string    `gorm:"type:char(64);primaryKey"`
+	Nonce        string    `gorm:"type:varchar(64);not null"`
+	CodeVerifier string    `gorm:"type:varchar(128);not null"`
+	TenantID     string    `gorm:"type:varchar(63);not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for the OIDCState model
+func (OIDCState) TableName() string {
+	return "oidc_states"
+}
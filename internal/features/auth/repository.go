@@ -0,0 +1,383 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+)
+
+// errRefreshTokenReused is returned when a refresh token has already been rotated
+var errRefreshTokenReused = errors.New("refresh token reuse detected")
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Create(user *User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *Repository) FindByID(id uuid.UUID) (*User, error) {
+	var user User
+	err := r.db.Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// FindInTenant returns the user only if they belong to the tenant of ctx
+func (r *Repository) FindInTenant(ctx context.Context, id uuid.UUID) (*User, error) {
+	var user User
+	err := r.db.WithContext(ctx).Scopes(tenant.Scope).Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// TenantIDs returns the IDs of every tenant that has users
+func (r *Repository) TenantIDs(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := r.db.WithContext(ctx).Model(&User{}).Distinct().Pluck(tenant.Column, &ids).Error
+	return ids, err
+}
+
+func (r *Repository) FindByEmail(email string) (*User, error) {
+	var user User
+	err := r.db.Where("email = ?", email).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ExistsByUsernameOrEmail reports whether an account already uses the username or email
+func (r *Repository) ExistsByUsernameOrEmail(username, email string) (bool, error) {
+	var count int64
+	err := r.db.Model(&User{}).Where("username = ? OR email = ?", username, email).Count(&count).Error
+	return count > 0, err
+}
+
+// ExistsByUsername reports whether an account already uses the username
+func (r *Repository) ExistsByUsername(username string) (bool, error) {
+	var count int64
+	err := r.db.Model(&User{}).Where("username = ?", username).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateSession starts a new token family and stores its first refresh token
+func (r *Repository) CreateSession(family *TokenFamily, token *RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(family).Error; err != nil {
+			return err
+		}
+		token.FamilyID = family.ID
+		return tx.Create(token).Error
+	})
+}
+
+// FindRefreshToken loads a refresh token together with its family
+func (r *Repository) FindRefreshToken(id uuid.UUID) (*RefreshToken, error) {
+	var token RefreshToken
+	err := r.db.Preload("Family").Where("id = ?", id).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken atomically marks the old token as rotated and stores its
+// successor, recording the client on the session. It returns
+// errRefreshTokenReused if the old token was rotated by a concurrent request
+// in the meantime.
+func (r *Repository) RotateRefreshToken(oldID uuid.UUID, next *RefreshToken, ip, userAgent string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL", oldID).
+			Update("rotated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		err := tx.Model(&TokenFamily{}).
+			Where("id = ?", next.FamilyID).
+			Updates(map[string]interface{}{"last_used_at": now, "ip": ip, "user_agent": userAgent}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(next).Error
+	})
+}
+
+// RevokeFamily revokes every refresh token in the family
+func (r *Repository) RevokeFamily(familyID uuid.UUID) error {
+	return r.db.Model(&TokenFamily{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserFamilies revokes every active token family of the user
+func (r *Repository) RevokeUserFamilies(userID uuid.UUID) error {
+	return r.db.Model(&TokenFamily{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// FindActiveSessions returns the user's unrevoked token families used after
+// since, most recently used first
+func (r *Repository) FindActiveSessions(userID uuid.UUID, since time.Time) ([]TokenFamily, error) {
+	var families []TokenFamily
+	err := r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND COALESCE(last_used_at, created_at) > ?", userID, since).
+		Order("COALESCE(last_used_at, created_at) DESC").
+		Find(&families).Error
+	return families, err
+}
+
+// RevokeSession revokes a token family of the user and reports whether an
+// active one was found
+func (r *Repository) RevokeSession(userID, familyID uuid.UUID) (bool, error) {
+	result := r.db.Model(&TokenFamily{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", familyID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeOtherSessions revokes every active token family of the user except
+// keepID and returns the IDs of the revoked families
+func (r *Repository) RevokeOtherSessions(userID, keepID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&TokenFamily{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Model(&TokenFamily{}).
+			Where("id IN ?", ids).
+			Update("revoked_at", time.Now()).Error
+	})
+	return ids, err
+}
+
+// SaveMFASecret stores a new TOTP secret that is not enforced until MFA is enabled
+func (r *Repository) SaveMFASecret(userID uuid.UUID, secret string) error {
+	return r.db.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_secret":     secret,
+		"mfa_enabled_at": nil,
+		"mfa_last_step":  0,
+	}).Error
+}
+
+// EnableMFA enforces the stored TOTP secret and replaces the recovery codes
+func (r *Repository) EnableMFA(userID uuid.UUID, step int64, codes []RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_enabled_at": time.Now(),
+			"mfa_last_step":  step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+// DisableMFA removes the TOTP secret and every recovery code of the user
+func (r *Repository) DisableMFA(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"mfa_secret":     "",
+			"mfa_enabled_at": nil,
+			"mfa_last_step":  0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes in favour of new ones
+func (r *Repository) ReplaceRecoveryCodes(userID uuid.UUID, codes []RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID, codes []RecoveryCode) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseTOTPStep records the time step of an accepted code. It reports false if
+// a concurrent request already used this or a later step.
+func (r *Repository) UseTOTPStep(userID uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&User{}).
+		Where("id = ? AND mfa_last_step < ?", userID, step).
+		Update("mfa_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false if
+// the user has no unused code with this hash.
+func (r *Repository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result := r.db.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateUserToken stores a token and invalidates earlier unused tokens of the
+// same purpose, so only the most recent email link works.
+func (r *Repository) CreateUserToken(token *UserToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// ResetPassword consumes a password reset token and sets the new password
+// hash. Following the link also proves ownership of the email address.
+func (r *Repository) ResetPassword(tokenHash, passwordHash string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, PurposePasswordReset, tokenHash)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		return tx.Model(&User{}).Where("id = ?", token.UserID).Updates(map[string]interface{}{
+			"password_hash":     passwordHash,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		}).Error
+	})
+	return userID, err
+}
+
+// VerifyEmail consumes an email verification token and marks the email as verified
+func (r *Repository) VerifyEmail(tokenHash string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, PurposeEmailVerification, tokenHash)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&User{}).
+			Where("id = ? AND email_verified_at IS NULL", token.UserID).
+			Update("email_verified_at", time.Now()).Error
+	})
+}
+
+// consumeUserToken marks an unused, unexpired token as used. It returns
+// gorm.ErrRecordNotFound if there is no such token or it was consumed concurrently.
+func consumeUserToken(tx *gorm.DB, purpose, tokenHash string) (*UserToken, error) {
+	var token UserToken
+	err := tx.Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).
+		First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := tx.Model(&UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &token, nil
+}
+
+// CreateOIDCState stores a pending OpenID Connect login and deletes expired
+// ones left behind by logins that were never completed
+func (r *Repository) CreateOIDCState(state *OIDCState) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at <= ?", time.Now()).Delete(&OIDCState{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(state).Error
+	})
+}
+
+// ConsumeOIDCState deletes and returns an unexpired pending login. It returns
+// gorm.ErrRecordNotFound if there is none or it was consumed concurrently.
+func (r *Repository) ConsumeOIDCState(stateHash string) (*OIDCState, error) {
+	var state OIDCState
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("state_hash = ? AND expires_at > ?", stateHash, time.Now()).First(&state).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("state_hash = ?", stateHash).Delete(&OIDCState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// FindByIdentity returns the user linked to the provider account
+func (r *Repository) FindByIdentity(issuer, subject string) (*User, error) {
+	var user User
+	err := r.db.
+		Joins("JOIN user_identities ON user_identities.user_id = users.id").
+		Where("user_identities.issuer = ? AND user_identities.subject = ?", issuer, subject).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateIdentity links an existing user to a provider account
+func (r *Repository) CreateIdentity(identity *UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+// CreateWithIdentity creates a user together with their provider account link
+func (r *Repository) CreateWithIdentity(user *User, identity *UserIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
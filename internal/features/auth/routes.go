@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+)
+
+// Permissions required by the auth feature routes
+const (
+	PermUsersManage      = "users:manage"
+	PermUsersImpersonate = "users:impersonate"
+)
+
+// RegisterRoutes registers all auth feature routes. requireMFAPending accepts
+// only the mfa_pending token issued by login for accounts with MFA enabled.
+func RegisterRoutes(g *echo.Group, h *Handler, requireAuth, requireMFAPending echo.MiddlewareFunc) {
+	g.POST("/register", h.Register)
+	g.POST("/login", h.Login)
+	g.POST("/refresh", h.Refresh)
+	g.GET("/oidc/authorize", h.OIDCAuthorize)
+	g.POST("/oidc/callback", h.OIDCCallback)
+	g.POST("/logout", h.Logout, requireAuth)
+	g.GET("/sessions", h.ListSessions, requireAuth)
+	g.DELETE("/sessions/others", h.RevokeOtherSessions, requireAuth, middleware.RejectImpersonation)
+	g.DELETE("/sessions/:id", h.RevokeSession, requireAuth, middleware.RejectImpersonation)
+	g.POST("/users/:id/revoke", h.RevokeUser, requireAuth, middleware.RequirePermission(PermUsersManage))
+	g.POST("/users/:id/impersonate", h.Impersonate, requireAuth, middleware.RequirePermission(PermUsersImpersonate))
+	g.POST("/users/:id/unlock", h.Unlock, requireAuth, middleware.RequirePermission(PermUsersManage))
+	g.POST("/ips/:ip/unlock", h.UnlockIP, requireAuth, middleware.RequirePermission(PermUsersManage))
+
+	g.POST("/password/forgot", h.ForgotPassword)
+	g.POST("/password/reset", h.ResetPassword)
+	g.POST("/email/verification", h.RequestEmailVerification, requireAuth)
+	g.POST("/email/verify", h.VerifyEmail)
+
+	g.POST("/mfa/verify", h.VerifyMFA, requireMFAPending)
+	g.POST("/mfa/enroll", h.EnrollMFA, requireAuth, middleware.RejectImpersonation)
+	g.POST("/mfa/enable", h.EnableMFA, requireAuth, middleware.RejectImpersonation)
+	g.POST("/mfa/disable", h.DisableMFA, requireAuth, middleware.RejectImpersonation)
+	g.POST("/mfa/recovery-codes", h.RegenerateRecoveryCodes, requireAuth, middleware.RejectImpersonation)
+}
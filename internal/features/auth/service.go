@@ -0,0 +1,1208 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
+	apperrors "github.com/SuperIntelligence-Labs/go-backend-template/internal/errors"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/keyring"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/lockout"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/mailer"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/oidc"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/revocation"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/totp"
+)
+
+const defaultRole = "user"
+
+const recoveryCodeCount = 10
+
+var (
+	errMFANotEnrolled = errors.New("mfa enrollment has not been started")
+	errMFANotEnabled  = errors.New("mfa is not enabled")
+
+	errNestedImpersonation     = errors.New("impersonation tokens cannot impersonate")
+	errSelfImpersonation       = errors.New("users cannot impersonate themselves")
+	errImpersonationEscalation = errors.New("target has permissions the impersonator does not hold")
+
+	errOIDCDisabled        = errors.New("oidc is not configured")
+	errOIDCEmailUnverified = errors.New("oidc provider did not verify the email address")
+
+	// errSharedLockout is returned when a tenant other than the default one
+	// tries to lift an IP lockout, since those apply to every tenant
+	errSharedLockout = errors.New("ip lockouts can only be lifted from the default tenant")
+)
+
+// Lifetimes of the tokens sent by email
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 24 * time.Hour
+)
+
+// oidcStateTTL bounds how long a user may take to log in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// usernameDisallowed matches what provisioned usernames may not contain,
+// matching the alphanum rule of RegisterRequest
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+type Service struct {
+	repo        *Repository
+	cfg         *config.Config
+	accessKeys  *keyring.Keyring
+	refreshKeys *keyring.Keyring
+	revocations revocation.Store
+	mail        *mailer.Queue
+	limiter     *lockout.Limiter
+	mailLimiter *lockout.Limiter // throttles password reset and verification emails
+	sso         *oidc.Provider   // nil unless OIDC is configured
+	recorder    audit.Recorder
+	permissions middleware.PermissionResolver
+}
+
+func NewService(repo *Repository, cfg *config.Config, accessKeys, refreshKeys *keyring.Keyring, revocations revocation.Store, mail *mailer.Queue, limiter, mailLimiter *lockout.Limiter, sso *oidc.Provider, recorder audit.Recorder, permissions middleware.PermissionResolver) *Service {
+	return &Service{
+		repo:        repo,
+		cfg:         cfg,
+		accessKeys:  accessKeys,
+		refreshKeys: refreshKeys,
+		revocations: revocations,
+		mail:        mail,
+		limiter:     limiter,
+		mailLimiter: mailLimiter,
+		sso:         sso,
+		recorder:    recorder,
+		permissions: permissions,
+	}
+}
+
+// RegisterRequest represents the request payload for creating an account
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required,alphanum,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// LoginRequest represents the request payload for logging in
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RefreshRequest represents the request payload for refreshing or revoking tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// ForgotPasswordRequest represents the request payload for requesting a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request payload for setting a new password
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=128"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// VerifyEmailRequest represents the request payload for confirming an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required,max=128"`
+}
+
+// OIDCCallbackRequest carries the parameters the provider redirected back with
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=128"`
+}
+
+// Client describes the device a request comes from
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+// MFACodeRequest carries a TOTP code, or a recovery code where one is accepted
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+// UserResponse represents the response payload for a user
+type UserResponse struct {
+	ID            uuid.UUID `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	TenantID      string    `json:"tenant_id"`
+	MFAEnabled    bool      `json:"mfa_enabled"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     string    `json:"created_at"`
+}
+
+// TokenResponse represents an issued access and refresh token pair
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// ImpersonationResponse holds an access token for acting as another user.
+// There is no refresh token; a new one is requested once it expires.
+type ImpersonationResponse struct {
+	AccessToken string        `json:"access_token"`
+	TokenType   string        `json:"token_type"`
+	ExpiresIn   int           `json:"expires_in"` // access token lifetime in seconds
+	User        *UserResponse `json:"user"`
+}
+
+// SessionResponse represents a login session of the user
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Current    bool      `json:"current"` // the session of the requesting access token
+	CreatedAt  string    `json:"created_at"`
+	LastUsedAt string    `json:"last_used_at"`
+}
+
+// OIDCAuthorizationResponse holds the provider URL to send the user to
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// MFAChallengeResponse is returned by login instead of tokens when the
+// account has MFA enabled. The mfa_token is exchanged at /auth/mfa/verify.
+type MFAChallengeResponse struct {
+	MFAToken  string `json:"mfa_token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int    `json:"expires_in"` // seconds left to enter the code
+}
+
+// MFAEnrollmentResponse holds the TOTP secret to add to an authenticator app
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// RecoveryCodesResponse lists recovery codes, which are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Register creates an account in the tenant of the request context
+func (s *Service) Register(ctx context.Context, req RegisterRequest) (*UserResponse, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+
+	email := normalizeEmail(req.Email)
+
+	exists, err := s.repo.ExistsByUsernameOrEmail(req.Username, email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, apperrors.ErrConflict
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		Username:     req.Username,
+		Email:        email,
+		PasswordHash: string(hash),
+		Role:         defaultRole,
+		TenantID:     tenantID,
+	}
+
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+
+	// The account is usable right away, so verification problems must not fail registration
+	if err := s.sendEmailVerification(user); err != nil {
+		logger.Error().Err(err).Str("user_id", user.ID.String()).Msg("Failed to create email verification token")
+	}
+
+	return toResponse(user), nil
+}
+
+// Login checks the password and starts a session. Accounts with MFA enabled
+// get a challenge instead, to be completed with VerifyMFA. Failures are
+// counted per account and IP address; while either is locked out a
+// *lockout.LockedError is returned without checking the password.
+func (s *Service) Login(ctx context.Context, req LoginRequest, client Client) (*TokenResponse, *MFAChallengeResponse, error) {
+	email := normalizeEmail(req.Email)
+	if err := s.limiter.Check(ctx, email, client.IP); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repo.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, s.loginFailed(ctx, email, client.IP)
+		}
+		return nil, nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, nil, s.loginFailed(ctx, email, client.IP)
+	}
+
+	// Failures are only cleared once the second factor has been checked too
+	if user.MFAEnabled() {
+		challenge, err := s.issueMFAChallenge(user)
+		return nil, challenge, err
+	}
+
+	if err := s.limiter.Succeed(ctx, email); err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	return tokens, nil, err
+}
+
+// VerifyMFA completes a login with a TOTP or recovery code. The mfa_pending
+// token is revoked so it cannot be exchanged twice. Wrong codes count towards
+// the same lockout as wrong passwords.
+func (s *Service) VerifyMFA(ctx context.Context, claims *middleware.JWTClaims, req MFACodeRequest, client Client) (*TokenResponse, error) {
+	user, err := s.repo.FindByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUnauthorized
+		}
+		return nil, err
+	}
+	if !user.MFAEnabled() {
+		return nil, apperrors.ErrUnauthorized
+	}
+
+	if err := s.limiter.Check(ctx, user.Email, client.IP); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkMFACode(user, req.Code, true); err != nil {
+		if errors.Is(err, apperrors.ErrUnauthorized) {
+			return nil, s.loginFailed(ctx, user.Email, client.IP)
+		}
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	if err := s.limiter.Succeed(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client)
+}
+
+// StartOIDCLogin begins a login at the OpenID Connect provider. The state,
+// nonce and PKCE verifier are stored until the callback, bound to the tenant
+// of the request context.
+func (s *Service) StartOIDCLogin(ctx context.Context) (*OIDCAuthorizationResponse, error) {
+	if s.sso == nil {
+		return nil, errOIDCDisabled
+	}
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.CreateOIDCState(&OIDCState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		TenantID:     tenantID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCAuthorizationResponse{
+		AuthorizationURL: s.sso.AuthCodeURL(state, nonce, oidc.Challenge(verifier)),
+	}, nil
+}
+
+// CompleteOIDCLogin redeems the authorization code, verifies the ID token and
+// logs in the linked user, provisioning one on first login. Like Login it
+// returns an MFA challenge for accounts with MFA enabled.
+func (s *Service) CompleteOIDCLogin(ctx context.Context, req OIDCCallbackRequest, client Client) (*TokenResponse, *MFAChallengeResponse, error) {
+	if s.sso == nil {
+		return nil, nil, errOIDCDisabled
+	}
+
+	state, err := s.repo.ConsumeOIDCState(hashToken(req.State))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, apperrors.ErrUnauthorized
+		}
+		return nil, nil, err
+	}
+	if tenantID, _ := tenant.FromContext(ctx); tenantID != state.TenantID {
+		return nil, nil, apperrors.ErrUnauthorized
+	}
+
+	rawIDToken, err := s.sso.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		logger.Warn().Err(err).Msg("OIDC code exchange failed")
+		return nil, nil, apperrors.ErrUnauthorized
+	}
+	claims, err := s.sso.Verify(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		logger.Warn().Err(err).Msg("OIDC ID token rejected")
+		return nil, nil, apperrors.ErrUnauthorized
+	}
+
+	user, err := s.findOrProvisionOIDCUser(claims, state.TenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.MFAEnabled() {
+		challenge, err := s.issueMFAChallenge(user)
+		return nil, challenge, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	return tokens, nil, err
+}
+
+// findOrProvisionOIDCUser returns the user linked to the provider account.
+// Without a link, the account is linked to the user with the same verified
+// email, or a new user is created just in time.
+func (s *Service) findOrProvisionOIDCUser(claims *oidc.Claims, tenantID string) (*User, error) {
+	issuer := s.sso.Issuer()
+
+	user, err := s.repo.FindByIdentity(issuer, claims.Subject)
+	if err == nil {
+		if user.TenantID != tenantID {
+			return nil, apperrors.ErrUnauthorized
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Matching users by email is only safe for addresses the provider vouches for
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errOIDCEmailUnverified
+	}
+	email := normalizeEmail(claims.Email)
+	identity := &UserIdentity{Issuer: issuer, Subject: claims.Subject, Email: email}
+
+	user, err = s.repo.FindByEmail(email)
+	if err == nil {
+		// Linking an unverified account would let whoever registered it keep
+		// access through its password
+		if user.TenantID != tenantID || user.EmailVerifiedAt == nil {
+			return nil, apperrors.ErrConflict
+		}
+		identity.UserID = user.ID
+		if err := s.repo.CreateIdentity(identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// Provisioned users sign in through the provider; a random password
+	// keeps password login closed until they reset it
+	password, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user = &User{
+		Username:        username,
+		Email:           email,
+		PasswordHash:    string(hash),
+		Role:            defaultRole,
+		TenantID:        tenantID,
+		EmailVerifiedAt: &now,
+	}
+	if err := s.repo.CreateWithIdentity(user, identity); err != nil {
+		return nil, err
+	}
+
+	logger.Info().Str("user_id", user.ID.String()).Str("issuer", issuer).Msg("Provisioned user from OIDC login")
+	return user, nil
+}
+
+// availableUsername derives a free username from the preferred_username or
+// email claim, adding a random suffix if it is taken
+func (s *Service) availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameDisallowed.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+	base = base[:min(len(base), 40)]
+
+	username := base
+	for range 5 {
+		exists, err := s.repo.ExistsByUsername(username)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+
+		suffix, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+		if err != nil {
+			return "", err
+		}
+		username = fmt.Sprintf("%s%06d", base, suffix)
+	}
+	return "", apperrors.ErrConflict
+}
+
+// Unlock lifts a login lockout of an account in the caller's tenant
+func (s *Service) Unlock(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repo.FindInTenant(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.limiter.UnlockAccount(ctx, user.Email)
+}
+
+// UnlockIP lifts a login lockout of an IP address. IP lockouts count failures
+// across all tenants, so only callers in the default tenant may lift them.
+func (s *Service) UnlockIP(ctx context.Context, ip string) error {
+	if tenantID, _ := tenant.FromContext(ctx); tenantID != s.cfg.Tenant.Default {
+		return errSharedLockout
+	}
+	return s.limiter.UnlockIP(ctx, ip)
+}
+
+// loginFailed records a failed attempt and returns the error for it
+func (s *Service) loginFailed(ctx context.Context, email, ip string) error {
+	if err := s.limiter.Fail(ctx, email, ip); err != nil {
+		return err
+	}
+	return apperrors.ErrUnauthorized
+}
+
+// EnrollMFA generates a TOTP secret for the user. MFA is not enforced until
+// the secret has been confirmed with EnableMFA; enrolling again replaces it.
+func (s *Service) EnrollMFA(userID uuid.UUID) (*MFAEnrollmentResponse, error) {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, apperrors.ErrConflict
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SaveMFASecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	return &MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.cfg.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// EnableMFA confirms the enrolled secret with a TOTP code and returns the
+// first set of recovery codes.
+func (s *Service) EnableMFA(userID uuid.UUID, req MFACodeRequest) (*RecoveryCodesResponse, error) {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, apperrors.ErrConflict
+	}
+	if user.MFASecret == "" {
+		return nil, errMFANotEnrolled
+	}
+
+	step, ok := totp.Validate(user.MFASecret, strings.TrimSpace(req.Code), time.Now(), user.MFALastStep)
+	if !ok {
+		return nil, apperrors.ErrUnauthorized
+	}
+
+	codes, records, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.EnableMFA(user.ID, step, records); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableMFA turns MFA off after checking a TOTP or recovery code
+func (s *Service) DisableMFA(userID uuid.UUID, req MFACodeRequest) error {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() {
+		return errMFANotEnabled
+	}
+
+	if err := s.checkMFACode(user, req.Code, true); err != nil {
+		return err
+	}
+
+	return s.repo.DisableMFA(user.ID)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a TOTP code
+func (s *Service) RegenerateRecoveryCodes(userID uuid.UUID, req MFACodeRequest) (*RecoveryCodesResponse, error) {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled() {
+		return nil, errMFANotEnabled
+	}
+
+	if err := s.checkMFACode(user, req.Code, false); err != nil {
+		return nil, err
+	}
+
+	codes, records, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(user.ID, records); err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Refresh rotates a refresh token. Presenting a token that has already been
+// rotated is treated as theft and revokes the whole family.
+func (s *Service) Refresh(ctx context.Context, req RefreshRequest, client Client) (*TokenResponse, error) {
+	current, err := s.findActiveRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.RotatedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, current)
+	}
+
+	// Reload the user so role changes and deletions take effect on refresh
+	user, err := s.repo.FindByID(current.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	tokens, next, err := s.issueTokens(user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RotateRefreshToken(current.ID, next, client.IP, truncate(client.UserAgent, 512)); err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			return nil, s.revokeReusedFamily(ctx, current)
+		}
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the caller's access token and the session of the given
+// refresh token, which must belong to the caller.
+func (s *Service) Logout(ctx context.Context, claims *middleware.JWTClaims, req RefreshRequest) error {
+	current, err := s.findActiveRefreshToken(req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if current.UserID != claims.UserID {
+		return apperrors.ErrUnauthorized
+	}
+
+	if err := s.repo.RevokeFamily(current.FamilyID); err != nil {
+		return err
+	}
+	if err := s.revokeSessionTokens(ctx, current.FamilyID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func (s *Service) ListSessions(claims *middleware.JWTClaims) ([]SessionResponse, error) {
+	rtTTL := time.Duration(s.cfg.JWT.RTExpiresIn) * time.Minute
+	families, err := s.repo.FindActiveSessions(claims.UserID, time.Now().Add(-rtTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]SessionResponse, len(families))
+	for i, family := range families {
+		responses[i] = *toSessionResponse(&family, claims.SessionID)
+	}
+
+	return responses, nil
+}
+
+// RevokeSession signs one of the user's sessions out. Its refresh tokens stop
+// working and its access tokens are denylisted by their sid claim.
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	revoked, err := s.repo.RevokeSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return gorm.ErrRecordNotFound
+	}
+
+	return s.revokeSessionTokens(ctx, sessionID)
+}
+
+// RevokeOtherSessions signs out every session of the user except the one of
+// the requesting access token
+func (s *Service) RevokeOtherSessions(ctx context.Context, claims *middleware.JWTClaims) error {
+	// Tokens issued before session IDs existed cannot be matched to a session
+	current, _ := uuid.Parse(claims.SessionID)
+
+	ids, err := s.repo.RevokeOtherSessions(claims.UserID, current)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.revokeSessionTokens(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeSessionTokens denylists the access tokens of a session until the last
+// of them has expired
+func (s *Service) revokeSessionTokens(ctx context.Context, sessionID uuid.UUID) error {
+	atTTL := time.Duration(s.cfg.JWT.ATExpiresIn) * time.Minute
+	return s.revocations.RevokeToken(ctx, sessionID.String(), time.Now().Add(atTTL))
+}
+
+// RevokeUser ends every session of a user in the caller's tenant and rejects
+// all access tokens issued to them so far, e.g. when an account is disabled.
+func (s *Service) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	if _, err := s.repo.FindInTenant(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.revokeUser(ctx, userID)
+}
+
+func (s *Service) revokeUser(ctx context.Context, userID uuid.UUID) error {
+	if err := s.repo.RevokeUserFamilies(userID); err != nil {
+		return err
+	}
+
+	return s.revocations.RevokeUser(ctx, userID, time.Now())
+}
+
+// Impersonate issues a short-lived access token that lets an admin act as
+// another user of their tenant. The token carries the admin in its act claim
+// and issuing it is recorded in the audit trail. Only users whose permissions
+// the admin holds as well, as reported by holds, can be impersonated.
+func (s *Service) Impersonate(ctx context.Context, claims *middleware.JWTClaims, userID uuid.UUID, holds func(permission string) bool) (*ImpersonationResponse, error) {
+	if claims.Impersonated() {
+		return nil, errNestedImpersonation
+	}
+	if claims.UserID == userID {
+		return nil, errSelfImpersonation
+	}
+
+	// Users of other tenants are reported as missing
+	user, err := s.repo.FindInTenant(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	granted, err := s.permissions.RolePermissions(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	for _, permission := range granted {
+		if !holds(permission) {
+			return nil, errImpersonationEscalation
+		}
+	}
+
+	ttl := time.Duration(s.cfg.JWT.ImpersonationExpiresIn) * time.Minute
+	tokenClaims := newClaims(user, middleware.TokenTypeAccess, time.Now(), ttl)
+	tokenClaims.Actor = &middleware.Actor{UserID: claims.UserID, Username: claims.Username}
+
+	// No token is handed out unless it has been recorded
+	err = s.recorder.Record(ctx, &audit.Entry{
+		Action:       "impersonation.start",
+		ResourceType: "user",
+		ResourceID:   user.ID.String(),
+		Details: audit.JSON{
+			"jti":        tokenClaims.ID,
+			"expires_at": tokenClaims.ExpiresAt.Time.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := s.accessKeys.Sign(tokenClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImpersonationResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(ttl.Seconds()),
+		User:        toResponse(user),
+	}, nil
+}
+
+// findActiveRefreshToken verifies the refresh token signature and loads its
+// stored record, rejecting tokens that are unknown or whose family is revoked.
+func (s *Service) findActiveRefreshToken(tokenString string) (*RefreshToken, error) {
+	claims, err := middleware.ValidateRefreshToken(tokenString, s.refreshKeys)
+	if err != nil {
+		return nil, apperrors.ErrUnauthorized
+	}
+
+	id, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, apperrors.ErrUnauthorized
+	}
+
+	token, err := s.repo.FindRefreshToken(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperrors.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	if token.Family.RevokedAt != nil || token.UserID != claims.UserID {
+		return nil, apperrors.ErrUnauthorized
+	}
+
+	return token, nil
+}
+
+// revokeReusedFamily ends the session of a reused refresh token, including
+// the access tokens already issued for it, since either holder may be the thief
+func (s *Service) revokeReusedFamily(ctx context.Context, token *RefreshToken) error {
+	logger.Warn().
+		Str("user_id", token.UserID.String()).
+		Str("family_id", token.FamilyID.String()).
+		Msg("Refresh token reuse detected, revoking token family")
+
+	if err := s.repo.RevokeFamily(token.FamilyID); err != nil {
+		return err
+	}
+	if err := s.revokeSessionTokens(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return apperrors.ErrUnauthorized
+}
+
+// ForgotPassword emails a password reset link. Unknown addresses are
+// silently ignored so the endpoint does not reveal which accounts exist.
+// Requests are counted per address and IP address, known or not; past the
+// limit a *lockout.LockedError is returned.
+func (s *Service) ForgotPassword(ctx context.Context, req ForgotPasswordRequest, client Client) error {
+	email := normalizeEmail(req.Email)
+	if err := s.mailLimiter.Attempt(ctx, email, client.IP); err != nil {
+		return err
+	}
+
+	user, err := s.repo.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	token, err := s.createUserToken(user, PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendAsync(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.Username, humanDuration(passwordResetTTL), s.link("/reset-password", token),
+		),
+	})
+	return nil
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and
+// signs the user out everywhere. Invalid or expired tokens return
+// gorm.ErrRecordNotFound.
+func (s *Service) ResetPassword(ctx context.Context, req ResetPasswordRequest) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.repo.ResetPassword(hashToken(req.Token), string(hash))
+	if err != nil {
+		return err
+	}
+
+	// The token identifies the user, whatever tenant the request names
+	return s.revokeUser(ctx, userID)
+}
+
+// RequestEmailVerification sends a new verification link to the user. It is
+// throttled like ForgotPassword.
+func (s *Service) RequestEmailVerification(ctx context.Context, userID uuid.UUID, client Client) error {
+	user, err := s.repo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return apperrors.ErrConflict
+	}
+	if err := s.mailLimiter.Attempt(ctx, user.Email, client.IP); err != nil {
+		return err
+	}
+
+	return s.sendEmailVerification(user)
+}
+
+// VerifyEmail confirms the email address with a token from a verification
+// link. Invalid or expired tokens return gorm.ErrRecordNotFound.
+func (s *Service) VerifyEmail(req VerifyEmailRequest) error {
+	return s.repo.VerifyEmail(hashToken(req.Token))
+}
+
+func (s *Service) sendEmailVerification(user *User) error {
+	token, err := s.createUserToken(user, PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.sendAsync(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n",
+			user.Username, humanDuration(emailVerificationTTL), s.link("/verify-email", token),
+		),
+	})
+	return nil
+}
+
+// createUserToken stores the hash of a new random token and returns the token
+func (s *Service) createUserToken(user *User, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	err := s.repo.CreateUserToken(&UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// humanDuration formats whole hours for email texts, e.g. "24 hours"
+func humanDuration(d time.Duration) string {
+	if hours := int(d.Hours()); hours != 1 {
+		return fmt.Sprintf("%d hours", hours)
+	}
+	return "1 hour"
+}
+
+// link builds a frontend URL carrying the token
+func (s *Service) link(path, token string) string {
+	return strings.TrimSuffix(s.cfg.Mail.LinkBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendAsync queues mail for delivery in the background so that slow mail
+// servers do not delay responses, and response times do not reveal whether
+// an account exists
+func (s *Service) sendAsync(msg mailer.Message) {
+	if err := s.mail.Enqueue(msg); err != nil {
+		logger.Error().Err(err).Str("subject", msg.Subject).Msg("Failed to queue email")
+	}
+}
+
+// startSession creates a token family for a new login and issues its first tokens
+func (s *Service) startSession(user *User, client Client) (*TokenResponse, error) {
+	now := time.Now()
+	family := &TokenFamily{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		UserAgent:  truncate(client.UserAgent, 512),
+		IP:         client.IP,
+		LastUsedAt: &now,
+	}
+	tokens, record, err := s.issueTokens(user, family.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateSession(family, record); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// issueMFAChallenge signs the short-lived token that proves the password step
+func (s *Service) issueMFAChallenge(user *User) (*MFAChallengeResponse, error) {
+	ttl := time.Duration(s.cfg.MFA.TokenExpiresIn) * time.Minute
+
+	token, err := s.accessKeys.Sign(newClaims(user, middleware.TokenTypeMFAPending, time.Now(), ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MFAChallengeResponse{
+		MFAToken:  token,
+		TokenType: "Bearer",
+		ExpiresIn: int(ttl.Seconds()),
+	}, nil
+}
+
+// checkMFACode accepts a TOTP code that has not been used before or, if
+// allowRecovery is set, an unused recovery code, which is then consumed.
+func (s *Service) checkMFACode(user *User, code string, allowRecovery bool) error {
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), user.MFALastStep); ok {
+		used, err := s.repo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return apperrors.ErrUnauthorized
+		}
+		return nil
+	}
+
+	if allowRecovery {
+		used, err := s.repo.UseRecoveryCode(user.ID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+
+	return apperrors.ErrUnauthorized
+}
+
+func (s *Service) issueTokens(user *User, familyID uuid.UUID) (*TokenResponse, *RefreshToken, error) {
+	now := time.Now()
+	atTTL := time.Duration(s.cfg.JWT.ATExpiresIn) * time.Minute
+	rtTTL := time.Duration(s.cfg.JWT.RTExpiresIn) * time.Minute
+
+	atClaims := newClaims(user, middleware.TokenTypeAccess, now, atTTL)
+	atClaims.SessionID = familyID.String()
+	accessToken, err := s.accessKeys.Sign(atClaims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rtClaims := newClaims(user, middleware.TokenTypeRefresh, now, rtTTL)
+	rtClaims.SessionID = familyID.String()
+	refreshToken, err := s.refreshKeys.Sign(rtClaims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record := &RefreshToken{
+		ID:        uuid.MustParse(rtClaims.ID),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: rtClaims.ExpiresAt.Time,
+	}
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(atTTL.Seconds()),
+	}, record, nil
+}
+
+func newClaims(user *User, tokenType string, now time.Time, ttl time.Duration) *middleware.JWTClaims {
+	return &middleware.JWTClaims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		TenantID:  user.TenantID,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   user.ID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// newRecoveryCodes returns plaintext codes formatted as "xxxxx-xxxxx" and their records
+func newRecoveryCodes(userID uuid.UUID) ([]string, []RecoveryCode, error) {
+	codes := make([]string, recoveryCodeCount)
+	records := make([]RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = encoded[:5] + "-" + encoded[5:10]
+		records[i] = RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+	return codes, records, nil
+}
+
+// hashRecoveryCode ignores case and dashes so codes can be typed loosely
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.ReplaceAll(code, "-", "")))
+}
+
+// hashToken uses SHA-256 since the tokens it protects are random and long enough
+// that a slow password hash adds nothing
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func toResponse(user *User) *UserResponse {
+	if user == nil {
+		return nil
+	}
+	return &UserResponse{
+		ID:            user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		Role:          user.Role,
+		TenantID:      user.TenantID,
+		MFAEnabled:    user.MFAEnabled(),
+		EmailVerified: user.EmailVerifiedAt != nil,
+		CreatedAt:     user.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+func toSessionResponse(family *TokenFamily, currentSessionID string) *SessionResponse {
+	lastUsedAt := family.CreatedAt
+	if family.LastUsedAt != nil {
+		lastUsedAt = *family.LastUsedAt
+	}
+	return &SessionResponse{
+		ID:         family.ID,
+		UserAgent:  family.UserAgent,
+		IP:         family.IP,
+		Current:    family.ID.String() == currentSessionID,
+		CreatedAt:  family.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		LastUsedAt: lastUsedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
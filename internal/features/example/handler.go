@@ -1,16 +1,38 @@
 package example
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/patch"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
 )
 
+// maxPatchSize bounds the body of PATCH requests
+const maxPatchSize = 64 << 10
+
+// exportFlushRows is how many exported rows are sent to the client at once
+const exportFlushRows = 100
+
+// exportColumns are the columns of CSV exports
+var exportColumns = []string{"id", "owner_id", "name", "description", "version", "created_at", "updated_at"}
+
 type Handler struct {
 	service *Service
 }
@@ -21,6 +43,11 @@ func NewHandler(service *Service) *Handler {
 
 // Create handles POST /items
 func (h *Handler) Create(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
 	var req CreateItemRequest
 	if err := c.Bind(&req); err != nil {
 		return response.ErrBadRequest("Invalid request body", nil)
@@ -31,22 +58,154 @@ func (h *Handler) Create(c echo.Context) error {
 		return response.ErrValidationFailed(details)
 	}
 
-	item, err := h.service.Create(req)
+	item, err := h.service.Create(c.Request().Context(), access, req)
+	if err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	return response.Created(c, "Item created successfully", item, validators(item)...)
+}
+
+// bulkResponse reports the outcome of every operation of a bulk request
+type bulkResponse struct {
+	Atomic    bool              `json:"atomic"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []bulkEntryResult `json:"results"`
+}
+
+// bulkEntryResult is the outcome of one operation, with the HTTP status it
+// would have had as a request of its own
+type bulkEntryResult struct {
+	Index   int                        `json:"index"`
+	Status  int                        `json:"status"`
+	Item    *ItemResponse              `json:"item,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+	Details []response.ValidationError `json:"details,omitempty"`
+}
+
+// Bulk handles POST /items/bulk
+func (h *Handler) Bulk(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
+	var req BulkRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	// Validate every operation, reporting errors by index. Invalid
+	// operations fail an atomic request as a whole and are skipped otherwise.
+	results := make([]bulkEntryResult, len(req.Operations))
+	var details []response.ValidationError
+	var valid []BulkOperation
+	var validIndexes []int
+	for i, op := range req.Operations {
+		results[i].Index = i
+		errs := validateBulkOperation(c, op)
+		if len(errs) == 0 {
+			valid = append(valid, op)
+			validIndexes = append(validIndexes, i)
+			continue
+		}
+
+		results[i].Status = http.StatusUnprocessableEntity
+		results[i].Error = "Validation failed"
+		results[i].Details = errs
+		for _, e := range errs {
+			details = append(details, response.ValidationError{
+				Field:   fmt.Sprintf("operations[%d].%s", i, e.Field),
+				Message: e.Message,
+			})
+		}
+	}
+	if req.Atomic && len(details) > 0 {
+		return response.ErrValidationFailed(details)
+	}
+
+	outcomes, err := h.service.Bulk(c.Request().Context(), access, BulkRequest{Atomic: req.Atomic, Operations: valid})
 	if err != nil {
 		return response.ErrInternalError(err)
 	}
+	for j, outcome := range outcomes {
+		i := validIndexes[j]
+		results[i].Status, results[i].Error = bulkStatus(valid[j].Op, outcome.Err)
+		results[i].Item = outcome.Item
+	}
 
-	return response.Created(c, "Item created successfully", item)
+	resp := bulkResponse{Atomic: req.Atomic, Results: results}
+	for _, result := range results {
+		if result.Status < 300 {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	return response.OK(c, "Bulk request processed", resp)
+}
+
+// validateBulkOperation validates an operation and the create or update
+// request it stands for
+func validateBulkOperation(c echo.Context, op BulkOperation) []response.ValidationError {
+	err := c.Validate(&op)
+	if err == nil {
+		switch op.Op {
+		case "create":
+			req := op.CreateRequest()
+			err = c.Validate(&req)
+		case "update":
+			req := op.UpdateRequest()
+			err = c.Validate(&req)
+		}
+	}
+	if err != nil {
+		return response.ToValidationErrors(err)
+	}
+	return nil
+}
+
+// bulkStatus maps the outcome of a bulk operation to an HTTP status and error
+// message
+func bulkStatus(op string, err error) (int, string) {
+	switch {
+	case err == nil && op == "create":
+		return http.StatusCreated, ""
+	case err == nil && op == "delete":
+		return http.StatusNoContent, ""
+	case err == nil:
+		return http.StatusOK, ""
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, "Item not found"
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed, "Item has been modified"
+	case errors.Is(err, ErrBulkAborted):
+		return http.StatusFailedDependency, "Not applied because another operation failed"
+	}
+	logger.Error().Err(err).Str("op", op).Msg("Bulk item operation failed")
+	return http.StatusInternalServerError, "Internal server error"
 }
 
 // GetByID handles GET /items/:id
 func (h *Handler) GetByID(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return response.ErrBadRequest("Invalid item ID", nil)
 	}
 
-	item, err := h.service.GetByID(id)
+	item, err := h.service.GetByID(c.Request().Context(), access, id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return response.ErrNotFound("Item not found")
@@ -54,37 +213,50 @@ func (h *Handler) GetByID(c echo.Context) error {
 		return response.ErrInternalError(err)
 	}
 
-	return response.OK(c, "Item retrieved successfully", item)
+	return response.OK(c, "Item retrieved successfully", item, validators(item)...)
 }
 
 // GetAll handles GET /items
 func (h *Handler) GetAll(c echo.Context) error {
-	// Parse pagination parameters with defaults and limits
-	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+	return h.list(c, false, "Items retrieved successfully")
+}
 
-	// Apply defaults
-	if limit <= 0 {
-		limit = 20
-	}
-	// Enforce maximum limit to prevent DoS
-	if limit > 100 {
-		limit = 100
+// Trash handles GET /items/trash
+func (h *Handler) Trash(c echo.Context) error {
+	return h.list(c, true, "Trashed items retrieved successfully")
+}
+
+// list responds with a page of live or trashed items
+func (h *Handler) list(c echo.Context, trashed bool, message string) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
 	}
-	if offset < 0 {
-		offset = 0
+
+	req, details := parseListQuery(c)
+	if len(details) > 0 {
+		return response.ErrValidationFailed(details)
 	}
+	req.Filter.Trashed = trashed
 
-	items, err := h.service.GetAll(limit, offset)
+	items, total, neighbours, err := h.service.GetAll(c.Request().Context(), access, req)
 	if err != nil {
+		if errors.Is(err, listing.ErrInvalidCursor) {
+			return response.ErrValidationFailed([]response.ValidationError{listing.InvalidCursor})
+		}
 		return response.ErrInternalError(err)
 	}
 
-	return response.OK(c, "Items retrieved successfully", items)
+	return response.Paginated(c, message, items, req.Meta(total, neighbours), response.WithWeakETag())
 }
 
 // Update handles PUT /items/:id
 func (h *Handler) Update(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return response.ErrBadRequest("Invalid item ID", nil)
@@ -100,26 +272,116 @@ func (h *Handler) Update(c echo.Context) error {
 		return response.ErrValidationFailed(details)
 	}
 
-	item, err := h.service.Update(id, req)
+	item, err := h.service.Update(c.Request().Context(), access, id, req, ifMatchVersions(c))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return response.ErrNotFound("Item not found")
 		}
+		if errors.Is(err, ErrVersionMismatch) {
+			return response.ErrPreconditionFailed("Item has been modified; fetch it again and retry")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Item updated successfully", item, validators(item)...)
+}
+
+// Patch handles PATCH /items/:id with a JSON Merge Patch (RFC 7386) or a JSON
+// Patch (RFC 6902) of the item's name and description
+func (h *Handler) Patch(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid item ID", nil)
+	}
+
+	var applyPatch func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case patch.MergePatchType:
+		applyPatch = patch.Merge
+	case patch.JSONPatchType:
+		applyPatch = patch.Apply
+	default:
+		c.Response().Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		return response.ErrUnsupportedMediaType("Content-Type must be " + patch.MergePatchType + " or " + patch.JSONPatchType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxPatchSize+1))
+	if err != nil || len(body) > maxPatchSize {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	// Errors of applying the patch are returned as they are by the service
+	item, err := h.service.Patch(c.Request().Context(), access, id, ifMatchVersions(c), func(current CreateItemRequest) (CreateItemRequest, error) {
+		var next CreateItemRequest
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return next, err
+		}
+
+		doc, err = applyPatch(doc, body)
+		if errors.Is(err, patch.ErrMalformed) {
+			return next, response.ErrBadRequest("Invalid patch document: "+err.Error(), nil)
+		}
+		if errors.Is(err, patch.ErrNotApplicable) {
+			return next, response.ErrConflict("Patch cannot be applied: " + err.Error())
+		}
+		if err != nil {
+			return next, err
+		}
+
+		// The result must still be an item, so patches cannot add members
+		dec := json.NewDecoder(bytes.NewReader(doc))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&next); err != nil {
+			return next, response.ErrValidationFailed([]response.ValidationError{
+				{Field: "body", Message: "Patched item is invalid: " + strings.TrimPrefix(err.Error(), "json: ")},
+			})
+		}
+
+		if err := c.Validate(&next); err != nil {
+			return next, response.ErrValidationFailed(response.ToValidationErrors(err))
+		}
+		return next, nil
+	})
+	if err != nil {
+		var appErr *response.AppError
+		switch {
+		case errors.As(err, &appErr):
+			return appErr
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return response.ErrNotFound("Item not found")
+		case errors.Is(err, ErrVersionMismatch):
+			return response.ErrPreconditionFailed("Item has been modified; fetch it again and retry")
+		}
 		return response.ErrInternalError(err)
 	}
 
-	return response.OK(c, "Item updated successfully", item)
+	return response.OK(c, "Item updated successfully", item, validators(item)...)
 }
 
 // Delete handles DELETE /items/:id
 func (h *Handler) Delete(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		return response.ErrBadRequest("Invalid item ID", nil)
 	}
 
-	rowsAffected, err := h.service.Delete(id)
+	rowsAffected, err := h.service.Delete(c.Request().Context(), access, id, ifMatchVersions(c))
 	if err != nil {
+		if errors.Is(err, ErrVersionMismatch) {
+			return response.ErrPreconditionFailed("Item has been modified; fetch it again and retry")
+		}
 		return response.ErrInternalError(err)
 	}
 
@@ -129,3 +391,249 @@ func (h *Handler) Delete(c echo.Context) error {
 
 	return response.NoContent(c)
 }
+
+// Export handles GET /items/export, streaming every item matching the list
+// filters as CSV or NDJSON. Pagination parameters are ignored.
+func (h *Handler) Export(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
+	req, details := parseListQuery(c)
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "ndjson" {
+		details = append(details, response.ValidationError{Field: "format", Message: "Must be one of: csv ndjson"})
+	}
+	if len(details) > 0 {
+		return response.ErrValidationFailed(details)
+	}
+
+	res := c.Response()
+	var start, flush func() error
+	var write func(item *ItemResponse) error
+	if format == "csv" {
+		w := csv.NewWriter(res)
+		start = func() error {
+			return w.Write(exportColumns)
+		}
+		write = func(item *ItemResponse) error {
+			return w.Write([]string{
+				item.ID.String(),
+				item.OwnerID.String(),
+				csvSafe(item.Name),
+				csvSafe(item.Description),
+				strconv.Itoa(item.Version),
+				item.CreatedAt,
+				item.UpdatedAt,
+			})
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		enc := json.NewEncoder(res)
+		start = func() error { return nil }
+		write = func(item *ItemResponse) error {
+			return enc.Encode(item)
+		}
+		flush = func() error { return nil }
+	}
+
+	// The headers are only sent with the first item, once the query has run,
+	// so that failing to start the export still responds with an error.
+	// Without a Content-Length the response is sent with chunked encoding.
+	rows := 0
+	started := false
+	begin := func() error {
+		started = true
+		filename := "items-" + time.Now().UTC().Format("2006-01-02") + "." + format
+		res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+		if format == "csv" {
+			res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		} else {
+			res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		}
+		res.WriteHeader(http.StatusOK)
+		return start()
+	}
+	err = h.service.Export(c.Request().Context(), access, req.Filter, req.Sort, func(item *ItemResponse) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		if err := write(item); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			res.Flush()
+		}
+		return nil
+	})
+	if err != nil && !started {
+		return response.ErrInternalError(err)
+	}
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// The status has already been sent; abort the connection so the client
+		// cannot take the truncated export for a complete one
+		logger.Error().Err(err).Int("rows", rows).Msg("Item export failed")
+		panic(http.ErrAbortHandler)
+	}
+
+	res.Flush()
+	return nil
+}
+
+// csvSafe keeps spreadsheet applications from evaluating a cell as a formula
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// Restore handles POST /items/:id/restore
+func (h *Handler) Restore(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid item ID", nil)
+	}
+
+	item, err := h.service.Restore(c.Request().Context(), access, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("Item not found in trash")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Item restored successfully", item, validators(item)...)
+}
+
+// Purge handles DELETE /items/trash/:id
+func (h *Handler) Purge(c echo.Context) error {
+	access, err := accessFrom(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.ErrBadRequest("Invalid item ID", nil)
+	}
+
+	rowsAffected, err := h.service.Purge(c.Request().Context(), access, id)
+	if err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return response.ErrNotFound("Item not found in trash")
+	}
+
+	return response.NoContent(c)
+}
+
+// parseListQuery reads the filter, sort and pagination query parameters of
+// GET /items, reporting every invalid parameter by name
+func parseListQuery(c echo.Context) (ListItemsRequest, []response.ValidationError) {
+	var req ListItemsRequest
+	var details []response.ValidationError
+	invalid := func(field, message string) {
+		details = append(details, response.ValidationError{Field: field, Message: message})
+	}
+
+	req.PageRequest, details = listing.ParsePage(c, 20, 100)
+
+	req.Filter.Name = c.QueryParam("name")
+	if len(req.Filter.Name) > 255 {
+		invalid("name", "Must be at most 255 characters/items")
+	}
+	req.Filter.NameContains = c.QueryParam("name_contains")
+	if len(req.Filter.NameContains) > 255 {
+		invalid("name_contains", "Must be at most 255 characters/items")
+	}
+
+	timestamp := func(field string) *time.Time {
+		value := c.QueryParam(field)
+		if value == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			invalid(field, "Must be an RFC 3339 timestamp, e.g. 2024-01-31T15:04:05Z")
+			return nil
+		}
+		return &t
+	}
+	req.Filter.CreatedAfter = timestamp("created_after")
+	req.Filter.CreatedBefore = timestamp("created_before")
+
+	sorts, err := listing.ParseSort(c.QueryParam("sort"), SortFields)
+	if err != nil {
+		invalid("sort", "Invalid sort: "+err.Error())
+	}
+	req.Sort = sorts
+
+	return req, details
+}
+
+// accessFrom scopes item queries to the caller. Items of other owners are
+// reported as not found rather than forbidden so their existence is not leaked.
+func accessFrom(c echo.Context) (Access, error) {
+	claims, err := middleware.GetClaims(c)
+	if err != nil {
+		return Access{}, err
+	}
+
+	return Access{
+		UserID: claims.UserID,
+		All:    middleware.HasPermission(c, PermItemsAdmin),
+	}, nil
+}
+
+// validators tag a response with the version of the item as its ETag and the
+// time it was last updated
+func validators(item *ItemResponse) []response.Option {
+	return []response.Option{
+		response.WithETag(strconv.Itoa(item.Version)),
+		response.WithLastModified(item.updatedAt),
+	}
+}
+
+// ifMatchVersions returns the item versions listed in If-Match, or nil if the
+// request is unconditional. Tags that are not versions are left out, so a
+// header listing none of them matches no version.
+func ifMatchVersions(c echo.Context) []int {
+	tags, conditional := response.IfMatch(c)
+	if !conditional {
+		return nil
+	}
+
+	versions := []int{}
+	for _, tag := range tags {
+		if version, err := strconv.Atoi(tag); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
@@ -1,21 +1,54 @@
 package example
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Item represents an example database entity
 type Item struct {
-	ID          uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	Name        string    `gorm:"type:varchar(255);not null"`
-	Description string    `gorm:"type:text"`
-	CreatedAt   time.Time `gorm:"autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime"`
+	ID          uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID    string         `gorm:"type:varchar(63);not null;default:'';index"`
+	OwnerID     uuid.UUID      `gorm:"type:uuid;not null;index"`
+	Name        string         `gorm:"type:varchar(255);not null"`
+	Description string         `gorm:"type:text"`
+	Version     int            `gorm:"not null;default:1"` // incremented by every change, served as the ETag
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"` // set while the item is in the trash
 }
 
 // TableName returns the table name for the Item model
 func (Item) TableName() string {
 	return "items"
 }
+
+// MigrateOwner adds the owner column to an items table created before items
+// had owners, which AutoMigrate cannot do while the table has rows: the
+// column is added as nullable, existing items are given the nil UUID as their
+// owner, so that only callers with items:admin can access them, and only then
+// is it made NOT NULL. It must run before AutoMigrate and does nothing once
+// the column exists.
+func MigrateOwner(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&Item{}) || migrator.HasColumn(&Item{}, "OwnerID") {
+		return nil
+	}
+
+	statements := []string{
+		"ALTER TABLE items ADD COLUMN owner_id uuid",
+		"UPDATE items SET owner_id = '" + uuid.Nil.String() + "' WHERE owner_id IS NULL",
+		"ALTER TABLE items ALTER COLUMN owner_id SET NOT NULL",
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("migrate item owners: %w", err)
+			}
+		}
+		return nil
+	})
+}
@@ -1,57 +1,262 @@
 package example
 
 import (
+	"context"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
 )
 
+// Access describes whose items a caller may read and modify
+type Access struct {
+	UserID uuid.UUID
+	All    bool // granted by PermItemsAdmin
+}
+
+// scope limits a query to the caller's own items unless they may access all
+func (a Access) scope(db *gorm.DB) *gorm.DB {
+	if a.All {
+		return db
+	}
+	return db.Where("owner_id = ?", a.UserID)
+}
+
+// Filter narrows down listed items; zero values match everything
+type Filter struct {
+	Name          string // exact match
+	NameContains  string // case-insensitive substring match
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Trashed       bool // list soft deleted items instead of live ones
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (f Filter) scope(db *gorm.DB) *gorm.DB {
+	if f.Trashed {
+		db = db.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if f.Name != "" {
+		db = db.Where("name = ?", f.Name)
+	}
+	if f.NameContains != "" {
+		db = db.Where("name ILIKE ?", "%"+likeEscaper.Replace(f.NameContains)+"%")
+	}
+	if f.CreatedAfter != nil {
+		db = db.Where("created_at > ?", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *f.CreatedBefore)
+	}
+	return db
+}
+
+// Repository scopes every query to the tenant of the context it is given.
+// With row-level security enabled, queries also run in a transaction that
+// sets the tenant for the Postgres policy.
 type Repository struct {
-	db *gorm.DB
+	db               *gorm.DB
+	rowLevelSecurity bool
 }
 
-func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *gorm.DB, rowLevelSecurity bool) *Repository {
+	return &Repository{db: db, rowLevelSecurity: rowLevelSecurity}
 }
 
-func (r *Repository) Create(item *Item) error {
-	return r.db.Create(item).Error
+// Transaction runs fn with a repository whose queries all run in a single
+// transaction, which is rolled back if fn returns an error
+func (r *Repository) Transaction(ctx context.Context, fn func(tx *Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{db: tx, rowLevelSecurity: r.rowLevelSecurity})
+	})
+}
+
+// run executes fn with a tenant scoped session
+func (r *Repository) run(ctx context.Context, fn func(db *gorm.DB) error) error {
+	db := r.db.WithContext(ctx)
+	if r.rowLevelSecurity {
+		return tenant.Transaction(db, func(tx *gorm.DB) error {
+			return fn(tx.Scopes(tenant.Scope))
+		})
+	}
+	return fn(db.Scopes(tenant.Scope))
 }
 
-func (r *Repository) FindByID(id uuid.UUID) (*Item, error) {
+func (r *Repository) Create(ctx context.Context, item *Item) error {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return tenant.ErrMissing
+	}
+	item.TenantID = tenantID
+
+	return r.run(ctx, func(db *gorm.DB) error {
+		return db.Create(item).Error
+	})
+}
+
+func (r *Repository) FindByID(ctx context.Context, access Access, id uuid.UUID) (*Item, error) {
 	var item Item
-	err := r.db.Where("id = ?", id).First(&item).Error
+	err := r.run(ctx, func(db *gorm.DB) error {
+		return db.Scopes(access.scope).Where("id = ?", id).First(&item).Error
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &item, nil
 }
 
-func (r *Repository) FindAll(limit, offset int) ([]Item, error) {
+// FindAll returns the rows of the page as selected by listing.Page.Scope,
+// to be passed to listing.Result, and the number of items matching the filter
+func (r *Repository) FindAll(ctx context.Context, access Access, filter Filter, page listing.Page) ([]Item, int64, error) {
 	var items []Item
-	err := r.db.Limit(limit).Offset(offset).Order("created_at DESC").Find(&items).Error
-	return items, err
+	var total int64
+	err := r.run(ctx, func(db *gorm.DB) error {
+		filtered := db.Scopes(access.scope, filter.scope).Session(&gorm.Session{})
+		if err := filtered.Model(&Item{}).Count(&total).Error; err != nil {
+			return err
+		}
+		return filtered.Scopes(page.Scope).Find(&items).Error
+	})
+	return items, total, err
 }
 
-func (r *Repository) Update(item *Item) error {
-	return r.db.Save(item).Error
+// Stream passes the items matching the filter to fn one at a time, in sort
+// order, without loading them all into memory. It stops at the first error
+// returned by fn.
+func (r *Repository) Stream(ctx context.Context, access Access, filter Filter, sorts []listing.Sort, fn func(item *Item) error) error {
+	return r.run(ctx, func(db *gorm.DB) error {
+		rows, err := db.Model(&Item{}).Scopes(access.scope, filter.scope, listing.Order(sorts, "id")).Rows()
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var item Item
+			if err := db.ScanRows(rows, &item); err != nil {
+				return err
+			}
+			if err := fn(&item); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
 }
 
-// UpdateFields performs an atomic update of specific fields
-func (r *Repository) UpdateFields(id uuid.UUID, fields map[string]interface{}) error {
+// Update writes every field of an existing item. Unlike Save it never falls
+// back to an upsert, which could overwrite an item of another tenant.
+func (r *Repository) Update(ctx context.Context, item *Item) error {
+	return r.run(ctx, func(db *gorm.DB) error {
+		return db.Select("*").Omit(tenant.Column).Updates(item).Error
+	})
+}
+
+// versionScope limits a write to the given versions of an item; nil versions
+// match any
+func versionScope(versions []int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if versions == nil {
+			return db
+		}
+		return db.Where("version IN ?", versions)
+	}
+}
+
+// UpdateFields performs an atomic update of specific fields and increments the
+// version. It only updates the given versions of the item, if any.
+func (r *Repository) UpdateFields(ctx context.Context, access Access, id uuid.UUID, fields map[string]interface{}, versions []int) error {
 	if len(fields) == 0 {
 		return nil // No fields to update
 	}
-	result := r.db.Model(&Item{}).Where("id = ?", id).Updates(fields)
-	if result.Error != nil {
+	fields["version"] = gorm.Expr("version + 1")
+
+	return r.run(ctx, func(db *gorm.DB) error {
+		result := db.Model(&Item{}).Scopes(access.scope, versionScope(versions)).Where("id = ?", id).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// Delete moves the given versions of an item, if any, to the trash
+func (r *Repository) Delete(ctx context.Context, access Access, id uuid.UUID, versions []int) (int64, error) {
+	var rowsAffected int64
+	err := r.run(ctx, func(db *gorm.DB) error {
+		result := db.Scopes(access.scope, versionScope(versions)).Delete(&Item{}, "id = ?", id)
+		rowsAffected = result.RowsAffected
 		return result.Error
+	})
+	return rowsAffected, err
+}
+
+// FindTrashed returns an item in the trash
+func (r *Repository) FindTrashed(ctx context.Context, access Access, id uuid.UUID) (*Item, error) {
+	var item Item
+	err := r.run(ctx, func(db *gorm.DB) error {
+		return db.Unscoped().Scopes(access.scope).Where("id = ? AND deleted_at IS NOT NULL", id).First(&item).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	return &item, nil
+}
+
+// Restore takes an item out of the trash
+func (r *Repository) Restore(ctx context.Context, access Access, id uuid.UUID) (int64, error) {
+	var rowsAffected int64
+	err := r.run(ctx, func(db *gorm.DB) error {
+		result := db.Unscoped().Model(&Item{}).Scopes(access.scope).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	return rowsAffected, err
+}
+
+// Purge permanently deletes an item in the trash
+func (r *Repository) Purge(ctx context.Context, access Access, id uuid.UUID) (int64, error) {
+	var rowsAffected int64
+	err := r.run(ctx, func(db *gorm.DB) error {
+		result := db.Unscoped().Scopes(access.scope).Delete(&Item{}, "id = ? AND deleted_at IS NOT NULL", id)
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	return rowsAffected, err
+}
+
+// PurgeDeletedBefore permanently deletes up to limit items of the tenant that
+// were moved to the trash before the cutoff and returns them
+func (r *Repository) PurgeDeletedBefore(ctx context.Context, cutoff time.Time, limit int) ([]Item, error) {
+	tenantID, ok := tenant.FromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrMissing
 	}
-	return nil
+
+	var items []Item
+	err := r.run(ctx, func(db *gorm.DB) error {
+		return db.Unscoped().Clauses(clause.Returning{}).
+			Where("id IN (SELECT id FROM items WHERE tenant_id = ? AND deleted_at < ? LIMIT ?)", tenantID, cutoff, limit).
+			Delete(&items).Error
+	})
+	return items, err
 }
 
-func (r *Repository) Delete(id uuid.UUID) (int64, error) {
-	result := r.db.Delete(&Item{}, "id = ?", id)
-	return result.RowsAffected, result.Error
+// Record adds entries to the audit trail. Within Transaction they are only
+// stored if the transaction commits.
+func (r *Repository) Record(ctx context.Context, entries ...*audit.Entry) error {
+	return audit.Insert(ctx, r.db, entries...)
 }
@@ -1,12 +1,29 @@
 package example
 
-import "github.com/labstack/echo/v4"
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+)
+
+// Permissions required by the example feature routes
+const (
+	PermItemsRead  = "items:read"
+	PermItemsWrite = "items:write"
+	PermItemsAdmin = "items:admin" // access items of every owner
+)
 
 // RegisterRoutes registers all example feature routes
 func RegisterRoutes(g *echo.Group, h *Handler) {
-	g.POST("", h.Create)
-	g.GET("", h.GetAll)
-	g.GET("/:id", h.GetByID)
-	g.PUT("/:id", h.Update)
-	g.DELETE("/:id", h.Delete)
+	g.POST("", h.Create, middleware.RequirePermission(PermItemsWrite))
+	g.POST("/bulk", h.Bulk, middleware.RequirePermission(PermItemsWrite))
+	g.GET("", h.GetAll, middleware.RequirePermission(PermItemsRead))
+	g.GET("/trash", h.Trash, middleware.RequirePermission(PermItemsRead))
+	middleware.Streaming(g.GET("/export", h.Export, middleware.RequirePermission(PermItemsRead)))
+	g.GET("/:id", h.GetByID, middleware.RequirePermission(PermItemsRead))
+	g.PUT("/:id", h.Update, middleware.RequirePermission(PermItemsWrite))
+	g.PATCH("/:id", h.Patch, middleware.RequirePermission(PermItemsWrite))
+	g.DELETE("/:id", h.Delete, middleware.RequirePermission(PermItemsWrite))
+	g.POST("/:id/restore", h.Restore, middleware.RequirePermission(PermItemsWrite))
+	g.DELETE("/trash/:id", h.Purge, middleware.RequirePermission(PermItemsAdmin))
 }
@@ -1,15 +1,54 @@
 package example
 
 import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/listing"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
 )
 
+// resourceType identifies items in the audit trail
+const resourceType = "item"
+
+// ErrVersionMismatch is returned when a conditional change targets a version
+// of an item that is no longer current
+var ErrVersionMismatch = errors.New("item version mismatch")
+
+// ErrBulkAborted is the result of the operations of an atomic bulk request
+// that were rolled back or skipped because another operation failed
+var ErrBulkAborted = errors.New("bulk request aborted")
+
+// errRollback rolls back the transaction of an atomic bulk request
+var errRollback = errors.New("rollback")
+
+// errPatchConflict rolls back a patch of an item that changed after it was read
+var errPatchConflict = errors.New("item changed")
+
+// Service makes every change in one transaction with its audit entry, so a
+// change is never stored without being recorded.
 type Service struct {
-	repo *Repository
+	repo    *Repository
+	cursors *listing.Cursors
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+func NewService(repo *Repository, cursors *listing.Cursors) *Service {
+	return &Service{repo: repo, cursors: cursors}
+}
+
+// transaction runs fn with a service whose changes and audit entries are all
+// made in a single transaction, which is rolled back if fn returns an error
+func (s *Service) transaction(ctx context.Context, fn func(tx *Service) error) error {
+	return s.repo.Transaction(ctx, func(repo *Repository) error {
+		return fn(&Service{repo: repo, cursors: s.cursors})
+	})
 }
 
 // CreateItemRequest represents the request payload for creating an item
@@ -25,30 +64,109 @@ type UpdateItemRequest struct {
 	Description *string `json:"description" validate:"omitempty,max=1000"`
 }
 
+// patchAttempts bounds how often a patch is reapplied when the item changes
+// between reading and saving it
+const patchAttempts = 3
+
+// purgeBatchSize bounds how many trashed items are purged, and recorded, in
+// one transaction
+const purgeBatchSize = 1000
+
+// ListItemsRequest represents the filters, sort order and page of an item list
+type ListItemsRequest struct {
+	Filter Filter
+	Sort   []listing.Sort // defaults to newest first
+	listing.PageRequest
+}
+
+// SortFields whitelists the fields items can be sorted by, mapped to their columns
+var SortFields = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+var defaultSort = []listing.Sort{{Field: "created_at", Column: "created_at", Desc: true}}
+
+// BulkRequest represents the request payload for bulk operations on items.
+// Atomic requests apply all operations or none; otherwise each operation
+// succeeds or fails on its own.
+type BulkRequest struct {
+	Atomic     bool            `json:"atomic"`
+	Operations []BulkOperation `json:"operations" validate:"required,min=1,max=1000"`
+}
+
+// BulkOperation is a single create, update or delete of a bulk request. Name
+// and description are validated like CreateItemRequest for creations and like
+// UpdateItemRequest for updates.
+type BulkOperation struct {
+	Op          string  `json:"op" validate:"required,oneof=create update delete"`
+	ID          string  `json:"id" validate:"required_unless=Op create,omitempty,uuid"`
+	Version     *int    `json:"version"` // expected version, as with If-Match
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// CreateRequest returns the creation requested by the operation
+func (op BulkOperation) CreateRequest() CreateItemRequest {
+	var req CreateItemRequest
+	if op.Name != nil {
+		req.Name = *op.Name
+	}
+	if op.Description != nil {
+		req.Description = *op.Description
+	}
+	return req
+}
+
+// UpdateRequest returns the update requested by the operation
+func (op BulkOperation) UpdateRequest() UpdateItemRequest {
+	return UpdateItemRequest{Name: op.Name, Description: op.Description}
+}
+
+// BulkResult is the outcome of a bulk operation
+type BulkResult struct {
+	Item *ItemResponse // nil for deletions and failures
+	Err  error
+}
+
 // ItemResponse represents the response payload for an item
 type ItemResponse struct {
 	ID          uuid.UUID `json:"id"`
+	OwnerID     uuid.UUID `json:"owner_id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Version     int       `json:"version"`
 	CreatedAt   string    `json:"created_at"`
 	UpdatedAt   string    `json:"updated_at"`
+	DeletedAt   *string   `json:"deleted_at,omitempty"` // only for items in the trash
+
+	updatedAt time.Time // for Last-Modified
 }
 
-func (s *Service) Create(req CreateItemRequest) (*ItemResponse, error) {
+func (s *Service) Create(ctx context.Context, access Access, req CreateItemRequest) (*ItemResponse, error) {
 	item := &Item{
+		OwnerID:     access.UserID,
 		Name:        req.Name,
 		Description: req.Description,
 	}
 
-	if err := s.repo.Create(item); err != nil {
+	var created *ItemResponse
+	err := s.transaction(ctx, func(tx *Service) error {
+		if err := tx.repo.Create(ctx, item); err != nil {
+			return err
+		}
+		created = toResponse(item)
+		return tx.record(ctx, audit.ActionCreate, item.ID, nil, created)
+	})
+	if err != nil {
 		return nil, err
 	}
-
-	return toResponse(item), nil
+	return created, nil
 }
 
-func (s *Service) GetByID(id uuid.UUID) (*ItemResponse, error) {
-	item, err := s.repo.FindByID(id)
+func (s *Service) GetByID(ctx context.Context, access Access, id uuid.UUID) (*ItemResponse, error) {
+	item, err := s.repo.FindByID(ctx, access, id)
 	if err != nil {
 		return nil, err
 	}
@@ -56,10 +174,32 @@ func (s *Service) GetByID(id uuid.UUID) (*ItemResponse, error) {
 	return toResponse(item), nil
 }
 
-func (s *Service) GetAll(limit, offset int) ([]ItemResponse, error) {
-	items, err := s.repo.FindAll(limit, offset)
+// GetAll returns a page of items, the number of items matching the filter and
+// the cursors of the neighbouring pages. It returns listing.ErrInvalidCursor
+// if the cursor was not issued for the requested sort order.
+func (s *Service) GetAll(ctx context.Context, access Access, req ListItemsRequest) ([]ItemResponse, int64, listing.Neighbours, error) {
+	page := listing.Page{Sorts: req.Sort, Tiebreak: "id", Limit: req.Limit, Offset: req.Offset}
+	if len(page.Sorts) == 0 {
+		page.Sorts = defaultSort
+	}
+	if req.Cursor != "" {
+		cursor, err := s.cursors.Decode(req.Cursor, page.Sorts)
+		if err != nil {
+			return nil, 0, listing.Neighbours{}, err
+		}
+		page.Cursor = cursor
+	}
+
+	items, total, err := s.repo.FindAll(ctx, access, req.Filter, page)
 	if err != nil {
-		return nil, err
+		return nil, 0, listing.Neighbours{}, err
+	}
+
+	items, neighbours, err := listing.Result(page, s.cursors, items, func(item Item) ([]interface{}, string) {
+		return sortValues(&item, page.Sorts), item.ID.String()
+	})
+	if err != nil {
+		return nil, 0, listing.Neighbours{}, err
 	}
 
 	responses := make([]ItemResponse, len(items))
@@ -67,41 +207,391 @@ func (s *Service) GetAll(limit, offset int) ([]ItemResponse, error) {
 		responses[i] = *toResponse(&item)
 	}
 
-	return responses, nil
+	return responses, total, neighbours, nil
+}
+
+// Export passes every item matching the filter to fn, in the requested sort
+// order, streaming them from the database
+func (s *Service) Export(ctx context.Context, access Access, filter Filter, sorts []listing.Sort, fn func(item *ItemResponse) error) error {
+	if len(sorts) == 0 {
+		sorts = defaultSort
+	}
+	return s.repo.Stream(ctx, access, filter, sorts, func(item *Item) error {
+		return fn(toResponse(item))
+	})
+}
+
+// Update changes the fields set in the request. Unless versions is nil, the
+// item must still be at one of them or ErrVersionMismatch is returned.
+func (s *Service) Update(ctx context.Context, access Access, id uuid.UUID, req UpdateItemRequest, versions []int) (*ItemResponse, error) {
+	var after *ItemResponse
+	err := s.transaction(ctx, func(tx *Service) error {
+		before, err := tx.GetByID(ctx, access, id)
+		if err != nil {
+			return err
+		}
+		if versions != nil && !slices.Contains(versions, before.Version) {
+			return ErrVersionMismatch
+		}
+
+		// Build update map for atomic update (fixes race condition)
+		updates := make(map[string]interface{})
+		if req.Name != nil {
+			updates["name"] = *req.Name
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+
+		// Perform atomic update; an item changed in the meantime is not updated
+		if err := tx.repo.UpdateFields(ctx, access, id, updates, versions); err != nil {
+			if versions != nil && errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrVersionMismatch
+			}
+			return err
+		}
+
+		// Fetch and return the updated item
+		after, err = tx.GetByID(ctx, access, id)
+		if err != nil {
+			return err
+		}
+
+		return tx.record(ctx, audit.ActionUpdate, id, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// Patch applies a PATCH request to the name and description of an item. apply
+// receives them as a CreateItemRequest and returns the patched and validated
+// result; its errors are returned unchanged. The result is only saved if the
+// item has not changed since apply saw it, otherwise the patch is applied to
+// the new state again. Unless versions is nil, the item must be at one of them
+// or ErrVersionMismatch is returned.
+func (s *Service) Patch(ctx context.Context, access Access, id uuid.UUID, versions []int, apply func(current CreateItemRequest) (CreateItemRequest, error)) (*ItemResponse, error) {
+	for range patchAttempts {
+		var after *ItemResponse
+		err := s.transaction(ctx, func(tx *Service) error {
+			before, err := tx.GetByID(ctx, access, id)
+			if err != nil {
+				return err
+			}
+			if versions != nil && !slices.Contains(versions, before.Version) {
+				return ErrVersionMismatch
+			}
+
+			current := CreateItemRequest{Name: before.Name, Description: before.Description}
+			patched, err := apply(current)
+			if err != nil {
+				return err
+			}
+			if patched == current {
+				after = before
+				return nil
+			}
+
+			updates := map[string]interface{}{"name": patched.Name, "description": patched.Description}
+			err = tx.repo.UpdateFields(ctx, access, id, updates, []int{before.Version})
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errPatchConflict
+			}
+			if err != nil {
+				return err
+			}
+
+			after, err = tx.GetByID(ctx, access, id)
+			if err != nil {
+				return err
+			}
+
+			return tx.record(ctx, audit.ActionUpdate, id, before, after)
+		})
+		if errors.Is(err, errPatchConflict) {
+			continue // changed or deleted in the meantime
+		}
+		if err != nil {
+			return nil, err
+		}
+		return after, nil
+	}
+	return nil, ErrVersionMismatch
+}
+
+// Bulk applies the operations of a validated bulk request in order. An atomic
+// request runs in one transaction that stops at the first failure; that
+// operation reports its error and every other one ErrBulkAborted. Otherwise
+// every operation runs on its own. The returned error is only set if the
+// request could not be processed at all.
+func (s *Service) Bulk(ctx context.Context, access Access, req BulkRequest) ([]BulkResult, error) {
+	results := make([]BulkResult, len(req.Operations))
+	if !req.Atomic {
+		for i, op := range req.Operations {
+			results[i] = s.applyBulk(ctx, access, op)
+		}
+		return results, nil
+	}
+
+	failed := -1
+	err := s.transaction(ctx, func(tx *Service) error {
+		for i, op := range req.Operations {
+			results[i] = tx.applyBulk(ctx, access, op)
+			if results[i].Err != nil {
+				failed = i
+				return errRollback
+			}
+		}
+		return nil
+	})
+	if failed >= 0 {
+		for i := range results {
+			if i != failed {
+				results[i] = BulkResult{Err: ErrBulkAborted}
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
-func (s *Service) Update(id uuid.UUID, req UpdateItemRequest) (*ItemResponse, error) {
-	// Build update map for atomic update (fixes race condition)
-	updates := make(map[string]interface{})
-	if req.Name != nil {
-		updates["name"] = *req.Name
+// applyBulk applies a single bulk operation
+func (s *Service) applyBulk(ctx context.Context, access Access, op BulkOperation) BulkResult {
+	var versions []int
+	if op.Version != nil {
+		versions = []int{*op.Version}
 	}
-	if req.Description != nil {
-		updates["description"] = *req.Description
+
+	if op.Op == "create" {
+		item, err := s.Create(ctx, access, op.CreateRequest())
+		return BulkResult{Item: item, Err: err}
 	}
 
-	// Perform atomic update
-	if err := s.repo.UpdateFields(id, updates); err != nil {
+	id, err := uuid.Parse(op.ID)
+	if err != nil {
+		return BulkResult{Err: gorm.ErrRecordNotFound}
+	}
+	if op.Op == "update" {
+		item, err := s.Update(ctx, access, id, op.UpdateRequest(), versions)
+		return BulkResult{Item: item, Err: err}
+	}
+
+	rowsAffected, err := s.Delete(ctx, access, id, versions)
+	if err == nil && rowsAffected == 0 {
+		err = gorm.ErrRecordNotFound
+	}
+	return BulkResult{Err: err}
+}
+
+// Delete moves an item to the trash, from where it can be restored until it
+// is purged. Unless versions is nil, the item must still be at one of them or
+// ErrVersionMismatch is returned.
+func (s *Service) Delete(ctx context.Context, access Access, id uuid.UUID, versions []int) (int64, error) {
+	var rowsAffected int64
+	err := s.transaction(ctx, func(tx *Service) error {
+		before, err := tx.GetByID(ctx, access, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if versions != nil && !slices.Contains(versions, before.Version) {
+			return ErrVersionMismatch
+		}
+
+		rowsAffected, err = tx.repo.Delete(ctx, access, id, versions)
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			if versions != nil {
+				return ErrVersionMismatch
+			}
+			return nil
+		}
+
+		return tx.record(ctx, audit.ActionDelete, id, before, nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// Restore takes an item out of the trash. It returns gorm.ErrRecordNotFound
+// if the item is not in the trash.
+func (s *Service) Restore(ctx context.Context, access Access, id uuid.UUID) (*ItemResponse, error) {
+	var after *ItemResponse
+	err := s.transaction(ctx, func(tx *Service) error {
+		trashed, err := tx.repo.FindTrashed(ctx, access, id)
+		if err != nil {
+			return err
+		}
+		before := toResponse(trashed)
+
+		rowsAffected, err := tx.repo.Restore(ctx, access, id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		after, err = tx.GetByID(ctx, access, id)
+		if err != nil {
+			return err
+		}
+
+		return tx.record(ctx, audit.ActionRestore, id, before, after)
+	})
+	if err != nil {
 		return nil, err
 	}
+	return after, nil
+}
+
+// Purge permanently deletes an item in the trash
+func (s *Service) Purge(ctx context.Context, access Access, id uuid.UUID) (int64, error) {
+	var rowsAffected int64
+	err := s.transaction(ctx, func(tx *Service) error {
+		before, err := tx.repo.FindTrashed(ctx, access, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		rowsAffected, err = tx.repo.Purge(ctx, access, id)
+		if err != nil || rowsAffected == 0 {
+			return err
+		}
+
+		return tx.record(ctx, audit.ActionPurge, id, toResponse(before), nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// PurgeTrash permanently deletes the items that have been in the trash for
+// longer than retention, every interval until ctx is done. It works through
+// the tenants returned by tenants one at a time, each under its own tenant
+// scope, so row-level security policies apply as they do to requests. Each
+// purge is recorded in the audit trail without an actor.
+func (s *Service) PurgeTrash(ctx context.Context, tenants func(ctx context.Context) ([]string, error), retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := tenants(ctx)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to list tenants to purge trashed items of")
+		}
+		cutoff := time.Now().Add(-retention)
+		for _, id := range ids {
+			purged, err := s.purgeDeletedBefore(tenant.WithID(ctx, id), cutoff)
+			if err != nil {
+				logger.Error().Err(err).Str("tenant_id", id).Msg("Failed to purge trashed items")
+			} else if purged > 0 {
+				logger.Info().Int64("count", purged).Str("tenant_id", id).Msg("Purged trashed items")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
 
-	// Fetch and return the updated item
-	return s.GetByID(id)
+// purgeDeletedBefore purges the items of the tenant of ctx trashed before the
+// cutoff in batches, each in one transaction with its audit entries, and
+// returns how many items it purged
+func (s *Service) purgeDeletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	for {
+		var items []Item
+		err := s.repo.Transaction(ctx, func(tx *Repository) error {
+			var err error
+			items, err = tx.PurgeDeletedBefore(ctx, cutoff, purgeBatchSize)
+			if err != nil {
+				return err
+			}
+
+			entries := make([]*audit.Entry, len(items))
+			for i := range items {
+				entry, err := audit.Change(audit.ActionPurge, resourceType, items[i].ID.String(), toResponse(&items[i]), nil)
+				if err != nil {
+					return err
+				}
+				entry.TenantID = items[i].TenantID
+				entry.Details = audit.JSON{"reason": "retention"}
+				entries[i] = entry
+			}
+			return tx.Record(ctx, entries...)
+		})
+		if err != nil {
+			return purged, err
+		}
+
+		purged += int64(len(items))
+		if len(items) < purgeBatchSize {
+			return purged, nil
+		}
+	}
 }
 
-func (s *Service) Delete(id uuid.UUID) (int64, error) {
-	return s.repo.Delete(id)
+// record adds a change to the audit trail. Called within transaction, the
+// change is rolled back if it cannot be recorded.
+func (s *Service) record(ctx context.Context, action string, id uuid.UUID, before, after *ItemResponse) error {
+	entry, err := audit.Change(action, resourceType, id.String(), before, after)
+	if err != nil {
+		return err
+	}
+	return s.repo.Record(ctx, entry)
+}
+
+// sortValues returns the values of the item's sort columns for its cursor
+func sortValues(item *Item, sorts []listing.Sort) []interface{} {
+	values := make([]interface{}, len(sorts))
+	for i, sort := range sorts {
+		switch sort.Field {
+		case "name":
+			values[i] = item.Name
+		case "created_at":
+			values[i] = item.CreatedAt
+		case "updated_at":
+			values[i] = item.UpdatedAt
+		}
+	}
+	return values
 }
 
 func toResponse(item *Item) *ItemResponse {
 	if item == nil {
 		return nil
 	}
-	return &ItemResponse{
+	response := &ItemResponse{
 		ID:          item.ID,
+		OwnerID:     item.OwnerID,
 		Name:        item.Name,
 		Description: item.Description,
+		Version:     item.Version,
 		CreatedAt:   item.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:   item.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		updatedAt:   item.UpdatedAt,
+	}
+	if item.DeletedAt.Valid {
+		deletedAt := item.DeletedAt.Time.Format("2006-01-02T15:04:05Z")
+		response.DeletedAt = &deletedAt
 	}
+	return response
 }
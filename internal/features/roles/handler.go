@@ -0,0 +1,182 @@
+package roles
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	apperrors "github.com/SuperIntelligence-Labs/go-backend-template/internal/errors"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+type Handler struct {
+	service *Service
+}
+
+func NewHandler(service *Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Create handles POST /roles
+func (h *Handler) Create(c echo.Context) error {
+	var req CreateRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if details := unauthorizedPermissions(c, req.Permissions); len(details) > 0 {
+		return response.ErrValidationFailed(details)
+	}
+
+	role, err := h.service.Create(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrConflict) {
+			return response.ErrConflict("Role already exists")
+		}
+		if errors.Is(err, errSharedRole) {
+			return response.ErrForbidden("Roles can only be changed from the default tenant")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.Created(c, "Role created successfully", role)
+}
+
+// GetByName handles GET /roles/:name
+func (h *Handler) GetByName(c echo.Context) error {
+	role, err := h.service.GetByName(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("Role not found")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Role retrieved successfully", role)
+}
+
+// GetAll handles GET /roles
+func (h *Handler) GetAll(c echo.Context) error {
+	roles, err := h.service.GetAll()
+	if err != nil {
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Roles retrieved successfully", roles)
+}
+
+// Update handles PUT /roles/:name
+func (h *Handler) Update(c echo.Context) error {
+	var req UpdateRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if req.Permissions != nil {
+		if details := unauthorizedPermissions(c, *req.Permissions); len(details) > 0 {
+			return response.ErrValidationFailed(details)
+		}
+	}
+
+	role, err := h.service.Update(c.Request().Context(), c.Param("name"), req, callerHolds(c))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("Role not found")
+		}
+		if errors.Is(err, errPermissionNotHeld) {
+			return response.ErrForbidden("Role grants permissions you do not have")
+		}
+		if errors.Is(err, errSharedRole) {
+			return response.ErrForbidden("Roles can only be changed from the default tenant")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.OK(c, "Role updated successfully", role)
+}
+
+// Delete handles DELETE /roles/:name
+func (h *Handler) Delete(c echo.Context) error {
+	rowsAffected, err := h.service.Delete(c.Request().Context(), c.Param("name"), callerHolds(c))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrConflict) {
+			return response.ErrConflict("Role is still assigned to users")
+		}
+		if errors.Is(err, errPermissionNotHeld) {
+			return response.ErrForbidden("Role grants permissions you do not have")
+		}
+		if errors.Is(err, errSharedRole) {
+			return response.ErrForbidden("Roles can only be changed from the default tenant")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	if rowsAffected == 0 {
+		return response.ErrNotFound("Role not found")
+	}
+
+	return response.NoContent(c)
+}
+
+// Assign handles POST /roles/:name/assign
+func (h *Handler) Assign(c echo.Context) error {
+	var req AssignRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return response.ErrBadRequest("Invalid request body", nil)
+	}
+
+	if err := c.Validate(&req); err != nil {
+		details := response.ToValidationErrors(err)
+		return response.ErrValidationFailed(details)
+	}
+
+	if err := h.service.Assign(c.Request().Context(), c.Param("name"), req, callerHolds(c)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return response.ErrNotFound("Role not found")
+		}
+		if errors.Is(err, errUserNotFound) {
+			return response.ErrNotFound("User not found")
+		}
+		if errors.Is(err, errPermissionNotHeld) {
+			return response.ErrForbidden("Role grants permissions you do not have")
+		}
+		return response.ErrInternalError(err)
+	}
+
+	return response.NoContent(c)
+}
+
+// callerHolds checks permissions against those loaded for the request
+func callerHolds(c echo.Context) Holds {
+	return func(permission string) bool {
+		return middleware.HasPermission(c, permission)
+	}
+}
+
+// unauthorizedPermissions reports permissions the caller does not hold, so a
+// role can never grant more than its creator has
+func unauthorizedPermissions(c echo.Context, permissions []string) []response.ValidationError {
+	var details []response.ValidationError
+	for i, permission := range permissions {
+		if !middleware.HasPermission(c, permission) {
+			details = append(details, response.ValidationError{
+				Field:   fmt.Sprintf("permissions[%d]", i),
+				Message: "You do not have the " + permission + " permission",
+			})
+		}
+	}
+	return details
+}
@@ -0,0 +1,28 @@
+package roles
+
+import "time"
+
+// Role is a named set of permissions that can be assigned to users
+type Role struct {
+	Name        string           `gorm:"type:varchar(50);primaryKey"`
+	Description string           `gorm:"type:varchar(255)"`
+	Permissions []RolePermission `gorm:"foreignKey:RoleName;constraint:OnDelete:CASCADE"`
+	CreatedAt   time.Time        `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time        `gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for the Role model
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RolePermission grants a permission such as "items:read" to a role
+type RolePermission struct {
+	RoleName   string `gorm:"type:varchar(50);primaryKey"`
+	Permission string `gorm:"type:varchar(100);primaryKey"`
+}
+
+// TableName returns the table name for the RolePermission model
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
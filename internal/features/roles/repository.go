@@ -0,0 +1,124 @@
+package roles
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/auth"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create stores the role together with its permissions
+func (r *Repository) Create(role *Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *Repository) FindByName(name string) (*Role, error) {
+	var role Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *Repository) FindAll() ([]Role, error) {
+	var roles []Role
+	err := r.db.Preload("Permissions").Order("name ASC").Find(&roles).Error
+	return roles, err
+}
+
+func (r *Repository) Exists(name string) (bool, error) {
+	var count int64
+	err := r.db.Model(&Role{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+// FindPermissions returns the permission names granted to the role
+func (r *Repository) FindPermissions(ctx context.Context, name string) ([]string, error) {
+	var permissions []string
+	err := r.db.WithContext(ctx).Model(&RolePermission{}).
+		Where("role_name = ?", name).
+		Pluck("permission", &permissions).Error
+	return permissions, err
+}
+
+// Update changes the description and, if permissions is non-nil, replaces
+// the role's permissions in a single transaction
+func (r *Repository) Update(name string, description *string, permissions []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		fields := map[string]interface{}{"updated_at": time.Now()}
+		if description != nil {
+			fields["description"] = *description
+		}
+
+		result := tx.Model(&Role{}).Where("name = ?", name).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if permissions == nil {
+			return nil
+		}
+		if err := tx.Where("role_name = ?", name).Delete(&RolePermission{}).Error; err != nil {
+			return err
+		}
+		if len(permissions) == 0 {
+			return nil
+		}
+		return tx.Create(toRolePermissions(name, permissions)).Error
+	})
+}
+
+func (r *Repository) Delete(name string) (int64, error) {
+	result := r.db.Delete(&Role{}, "name = ?", name)
+	return result.RowsAffected, result.Error
+}
+
+// CountUsers returns how many users currently have the role
+func (r *Repository) CountUsers(name string) (int64, error) {
+	var count int64
+	err := r.db.Model(&auth.User{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
+
+// FindUserRole returns the name of the role of a user in the tenant of ctx
+func (r *Repository) FindUserRole(ctx context.Context, userID uuid.UUID) (string, error) {
+	var user auth.User
+	err := r.db.WithContext(ctx).Scopes(tenant.Scope).Select("role").Where("id = ?", userID).First(&user).Error
+	return user.Role, err
+}
+
+// AssignToUser sets the role of a user in the tenant of ctx
+func (r *Repository) AssignToUser(ctx context.Context, name string, userID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&auth.User{}).Scopes(tenant.Scope).Where("id = ?", userID).Update("role", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func toRolePermissions(name string, permissions []string) []RolePermission {
+	rows := make([]RolePermission, len(permissions))
+	for i, permission := range permissions {
+		rows[i] = RolePermission{RoleName: name, Permission: permission}
+	}
+	return rows
+}
@@ -0,0 +1,23 @@
+package roles
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+)
+
+// Permissions required by the roles feature routes
+const (
+	PermRolesRead  = "roles:read"
+	PermRolesWrite = "roles:write"
+)
+
+// RegisterRoutes registers all roles feature routes
+func RegisterRoutes(g *echo.Group, h *Handler) {
+	g.POST("", h.Create, middleware.RequirePermission(PermRolesWrite))
+	g.GET("", h.GetAll, middleware.RequirePermission(PermRolesRead))
+	g.GET("/:name", h.GetByName, middleware.RequirePermission(PermRolesRead))
+	g.PUT("/:name", h.Update, middleware.RequirePermission(PermRolesWrite))
+	g.DELETE("/:name", h.Delete, middleware.RequirePermission(PermRolesWrite))
+	g.POST("/:name/assign", h.Assign, middleware.RequirePermission(PermRolesWrite))
+}
@@ -0,0 +1,275 @@
+package roles
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	apperrors "github.com/SuperIntelligence-Labs/go-backend-template/internal/errors"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+)
+
+var (
+	// errUserNotFound is returned when assigning a role to an unknown user
+	errUserNotFound = errors.New("user not found")
+
+	// errSharedRole is returned when a tenant other than the default one tries
+	// to change a role, since roles are shared by every tenant
+	errSharedRole = errors.New("roles can only be changed from the default tenant")
+
+	// errPermissionNotHeld is returned when the caller would change a role, or
+	// take a role away from a user, that grants more than the caller holds
+	errPermissionNotHeld = errors.New("role grants permissions the caller does not hold")
+)
+
+// Holds reports whether the caller holds a permission. Callers can only
+// manage roles within their own permissions, so roles:write is no way to
+// gain more of them.
+type Holds func(permission string) bool
+
+type Service struct {
+	repo          *Repository
+	defaultTenant string // the only tenant allowed to change roles
+}
+
+func NewService(repo *Repository, defaultTenant string) *Service {
+	return &Service{repo: repo, defaultTenant: defaultTenant}
+}
+
+// CreateRoleRequest represents the request payload for creating a role
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,alphanum,min=2,max=50"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"required,min=1,dive,required,max=100"`
+}
+
+// UpdateRoleRequest represents the request payload for updating a role
+// Uses pointer fields to distinguish between "not provided" and "set to empty"
+type UpdateRoleRequest struct {
+	Description *string   `json:"description" validate:"omitempty,max=255"`
+	Permissions *[]string `json:"permissions" validate:"omitempty,dive,required,max=100"`
+}
+
+// AssignRoleRequest represents the request payload for assigning a role to a user
+type AssignRoleRequest struct {
+	UserID uuid.UUID `json:"user_id" validate:"required"`
+}
+
+// RoleResponse represents the response payload for a role
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// Create adds a role. Roles are shared by all tenants, so like Update and
+// Delete it is only allowed from the default tenant.
+func (s *Service) Create(ctx context.Context, req CreateRoleRequest) (*RoleResponse, error) {
+	if err := s.checkSharedWrite(ctx); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.Exists(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, apperrors.ErrConflict
+	}
+
+	role := &Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: toRolePermissions(req.Name, dedupe(req.Permissions)),
+	}
+
+	if err := s.repo.Create(role); err != nil {
+		return nil, err
+	}
+
+	return s.GetByName(role.Name)
+}
+
+func (s *Service) GetByName(name string) (*RoleResponse, error) {
+	role, err := s.repo.FindByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return toResponse(role), nil
+}
+
+func (s *Service) GetAll() ([]RoleResponse, error) {
+	roles, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]RoleResponse, len(roles))
+	for i, role := range roles {
+		responses[i] = *toResponse(&role)
+	}
+
+	return responses, nil
+}
+
+// Update changes a role at runtime. Requests pick up the new permissions
+// immediately because they are resolved per request, not stored in tokens.
+// The caller must hold the current permissions; the handler checks the new ones.
+func (s *Service) Update(ctx context.Context, name string, req UpdateRoleRequest, holds Holds) (*RoleResponse, error) {
+	if err := s.checkSharedWrite(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.checkHeld(ctx, name, holds); err != nil {
+		return nil, err
+	}
+
+	var permissions []string
+	if req.Permissions != nil {
+		permissions = dedupe(*req.Permissions)
+	}
+
+	if err := s.repo.Update(name, req.Description, permissions); err != nil {
+		return nil, err
+	}
+
+	return s.GetByName(name)
+}
+
+// Delete removes a role that no user is assigned to
+func (s *Service) Delete(ctx context.Context, name string, holds Holds) (int64, error) {
+	if err := s.checkSharedWrite(ctx); err != nil {
+		return 0, err
+	}
+	if err := s.checkHeld(ctx, name, holds); err != nil {
+		return 0, err
+	}
+
+	users, err := s.repo.CountUsers(name)
+	if err != nil {
+		return 0, err
+	}
+	if users > 0 {
+		return 0, apperrors.ErrConflict
+	}
+
+	return s.repo.Delete(name)
+}
+
+// Assign gives a user of the caller's tenant the role; users of other tenants
+// are reported as missing. The user's current tokens keep the old
+// role name until they are refreshed. The caller must hold the permissions
+// of both the new role and the user's current one.
+func (s *Service) Assign(ctx context.Context, name string, req AssignRoleRequest, holds Holds) error {
+	exists, err := s.repo.Exists(name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return gorm.ErrRecordNotFound
+	}
+	if err := s.checkHeld(ctx, name, holds); err != nil {
+		return err
+	}
+
+	current, err := s.repo.FindUserRole(ctx, req.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errUserNotFound
+		}
+		return err
+	}
+	if err := s.checkHeld(ctx, current, holds); err != nil {
+		return err
+	}
+
+	if err := s.repo.AssignToUser(ctx, name, req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RolePermissions implements middleware.PermissionResolver
+func (s *Service) RolePermissions(ctx context.Context, role string) ([]string, error) {
+	return s.repo.FindPermissions(ctx, role)
+}
+
+// EnsureDefaults creates the given roles if they do not exist yet. Existing
+// roles are left untouched so runtime edits survive restarts.
+func (s *Service) EnsureDefaults(defaults map[string][]string) error {
+	for name, permissions := range defaults {
+		exists, err := s.repo.Exists(name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		role := &Role{Name: name, Permissions: toRolePermissions(name, dedupe(permissions))}
+		if err := s.repo.Create(role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSharedWrite returns errSharedRole unless the caller belongs to the
+// default tenant
+func (s *Service) checkSharedWrite(ctx context.Context) error {
+	if tenantID, _ := tenant.FromContext(ctx); tenantID != s.defaultTenant {
+		return errSharedRole
+	}
+	return nil
+}
+
+// checkHeld returns errPermissionNotHeld unless the caller holds every
+// permission of the role
+func (s *Service) checkHeld(ctx context.Context, role string, holds Holds) error {
+	permissions, err := s.repo.FindPermissions(ctx, role)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !holds(permission) {
+			return errPermissionNotHeld
+		}
+	}
+	return nil
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func toResponse(role *Role) *RoleResponse {
+	if role == nil {
+		return nil
+	}
+	permissions := make([]string, len(role.Permissions))
+	for i, p := range role.Permissions {
+		permissions[i] = p.Permission
+	}
+	return &RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:   role.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
@@ -0,0 +1,108 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Status describes what a key in the keyring may be used for.
+type Status string
+
+const (
+	StatusActive     Status = "active"      // signs new tokens and verifies existing ones
+	StatusVerifyOnly Status = "verify-only" // verifies tokens signed before a rotation
+	StatusRetired    Status = "retired"     // tokens signed with it are rejected
+)
+
+// Key is a JWT signing key identified by its kid.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	Status Status
+
+	signKey   interface{} // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+	verifyKey interface{} // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// Keyring signs tokens with its active key and verifies them with the key
+// named by the token's kid header. Rotating keys without logging users out
+// means promoting a new active key while the previous one stays verify-only
+// until the tokens it signed have expired, after which it is retired.
+type Keyring struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+// New creates a keyring that signs with the active key. The other keys are
+// kept for verification, or for rejecting tokens if their status is retired.
+func New(active *Key, others ...*Key) (*Keyring, error) {
+	if active == nil || active.signKey == nil {
+		return nil, errors.New("keyring requires an active key with signing material")
+	}
+	active.Status = StatusActive
+
+	keys := map[string]*Key{active.ID: active}
+	for _, key := range others {
+		if _, exists := keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id: %s", key.ID)
+		}
+		if key.Status != StatusRetired {
+			key.Status = StatusVerifyOnly
+		}
+		keys[key.ID] = key
+	}
+
+	return &Keyring{active: active, keys: keys}, nil
+}
+
+// Active returns the key used to sign new tokens.
+func (k *Keyring) Active() *Key {
+	return k.active
+}
+
+// Keys returns every key in the keyring ordered by kid.
+func (k *Keyring) Keys() []*Key {
+	keys := make([]*Key, 0, len(k.keys))
+	for _, key := range k.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Sign signs the claims with the active key and sets the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	token.Header["kid"] = k.active.ID
+	return token.SignedString(k.active.signKey)
+}
+
+// Parse verifies the token with the key selected by its kid and decodes it into claims.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, k.keyFunc)
+}
+
+// keyFunc selects the verification key by kid. Tokens without a kid were
+// issued before key IDs existed and are checked against the active key.
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	key := k.active
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = k.keys[kid]; !ok {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+	}
+
+	if key.Status == StatusRetired {
+		return nil, fmt.Errorf("signing key %s has been retired", key.ID)
+	}
+
+	// Validate signing algorithm to prevent algorithm confusion attacks
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
@@ -0,0 +1,101 @@
+package keyring
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
+)
+
+// Load builds the access and refresh token keyrings from configuration.
+// Access tokens may use HS256, RS256 or EdDSA; refresh tokens are only ever
+// verified by this service and always use HS256.
+func Load(cfg config.JWTConfig) (access *Keyring, refresh *Keyring, err error) {
+	retired := make(map[string]bool, len(cfg.RetiredKeyIDs))
+	for _, kid := range cfg.RetiredKeyIDs {
+		retired[kid] = true
+	}
+	for _, kid := range []string{cfg.ATKeyID, cfg.RTKeyID} {
+		if retired[kid] {
+			return nil, nil, fmt.Errorf("active key %s is listed as retired", kid)
+		}
+	}
+
+	accessKey, err := loadKey(cfg.ATKeyID, cfg.ATAlgorithm, cfg.ATSecret, cfg.ATPrivateKeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("access token key: %w", err)
+	}
+	accessVerify, err := parseVerifyKeys(cfg.ATVerifyKeys, retired)
+	if err != nil {
+		return nil, nil, fmt.Errorf("access token verify keys: %w", err)
+	}
+
+	refreshVerify, err := parseVerifyKeys(cfg.RTVerifyKeys, retired)
+	if err != nil {
+		return nil, nil, fmt.Errorf("refresh token verify keys: %w", err)
+	}
+	for _, key := range refreshVerify {
+		if key.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, nil, fmt.Errorf("refresh token key %s must use HS256", key.ID)
+		}
+	}
+
+	if access, err = New(accessKey, accessVerify...); err != nil {
+		return nil, nil, fmt.Errorf("access token keyring: %w", err)
+	}
+	if refresh, err = New(NewHMACKey(cfg.RTKeyID, []byte(cfg.RTSecret)), refreshVerify...); err != nil {
+		return nil, nil, fmt.Errorf("refresh token keyring: %w", err)
+	}
+
+	return access, refresh, nil
+}
+
+func loadKey(kid, alg, secret, privateKeyFile string) (*Key, error) {
+	if alg == jwt.SigningMethodHS256.Alg() {
+		return NewHMACKey(kid, []byte(secret)), nil
+	}
+
+	data, err := os.ReadFile(privateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	return ParsePrivateKeyPEM(kid, alg, data)
+}
+
+// parseVerifyKeys parses "kid:alg:material" specs, where material is the
+// secret for HS256 and a PEM file path for RS256 and EdDSA.
+func parseVerifyKeys(specs []string, retired map[string]bool) ([]*Key, error) {
+	keys := make([]*Key, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid key spec %q, expected kid:alg:material", spec)
+		}
+		kid, alg, material := parts[0], parts[1], parts[2]
+
+		var key *Key
+		switch alg {
+		case jwt.SigningMethodHS256.Alg():
+			key = NewHMACKey(kid, []byte(material))
+		case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+			data, err := os.ReadFile(material)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: failed to read public key: %w", kid, err)
+			}
+			if key, err = ParsePublicKeyPEM(kid, alg, data); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("key %s: unsupported algorithm %s", kid, alg)
+		}
+
+		if retired[kid] {
+			key.Status = StatusRetired
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
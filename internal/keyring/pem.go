@@ -0,0 +1,101 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// NewHMACKey creates an HS256 key from a shared secret.
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{
+		ID:        kid,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// ParsePrivateKeyPEM creates a key pair from a PEM encoded private key.
+// RS256 expects an RSA key (PKCS#1 or PKCS#8), EdDSA an Ed25519 key (PKCS#8).
+func ParsePrivateKeyPEM(kid, alg string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM data found", kid)
+	}
+
+	var parsed interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", kid, err)
+	}
+
+	switch priv := parsed.(type) {
+	case *rsa.PrivateKey:
+		if alg != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("key %s: RSA key cannot be used with %s", kid, alg)
+		}
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, signKey: priv, verifyKey: &priv.PublicKey}, nil
+	case ed25519.PrivateKey:
+		if alg != jwt.SigningMethodEdDSA.Alg() {
+			return nil, fmt.Errorf("key %s: Ed25519 key cannot be used with %s", kid, alg)
+		}
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, signKey: priv, verifyKey: priv.Public()}, nil
+	default:
+		return nil, errors.New("key " + kid + ": unsupported private key type")
+	}
+}
+
+// ParsePublicKeyPEM creates a verify-only key from a PEM encoded public key
+// (PKIX or PKCS#1). A private key PEM is also accepted; only its public half
+// is kept.
+func ParsePublicKeyPEM(kid, alg string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM data found", kid)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err := ParsePrivateKeyPEM(kid, alg, data)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey = nil
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", kid, err)
+	}
+
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		if alg != jwt.SigningMethodRS256.Alg() {
+			return nil, fmt.Errorf("key %s: RSA key cannot be used with %s", kid, alg)
+		}
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, verifyKey: pub}, nil
+	case ed25519.PublicKey:
+		if alg != jwt.SigningMethodEdDSA.Alg() {
+			return nil, fmt.Errorf("key %s: Ed25519 key cannot be used with %s", kid, alg)
+		}
+		return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, verifyKey: pub}, nil
+	default:
+		return nil, errors.New("key " + kid + ": unsupported public key type")
+	}
+}
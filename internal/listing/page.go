@@ -0,0 +1,118 @@
+package listing
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Page selects a page of a sorted list, either by offset or by keyset after
+// (or before) a cursor. Keyset pages stay fast on large tables and neither
+// skip nor repeat rows when rows are inserted or deleted in between. Sort
+// columns must not be NULL.
+type Page struct {
+	Sorts    []Sort
+	Tiebreak string // unique column ending the sort order, e.g. "id"
+	Limit    int
+	Offset   int     // ignored when Cursor is set
+	Cursor   *Cursor // decoded for Sorts
+}
+
+// Neighbours holds the cursors of the pages before and after a page; they
+// are empty at either end of the list
+type Neighbours struct {
+	Next string
+	Prev string
+}
+
+// Scope is a GORM scope selecting the page. It fetches one row more than the
+// limit, which Result uses to tell whether another page follows.
+func (p Page) Scope(db *gorm.DB) *gorm.DB {
+	sorts, tiebreakDesc := p.Sorts, false
+	if p.Cursor != nil {
+		// Pages before the cursor are read backwards and reversed by Result
+		if p.Cursor.Before {
+			sorts, tiebreakDesc = reverse(sorts), true
+		}
+		db = db.Where(seek(sorts, p.Cursor.Values, p.Tiebreak, tiebreakDesc, p.Cursor.ID))
+	} else {
+		db = db.Offset(p.Offset)
+	}
+
+	return db.Scopes(orderBy(sorts, p.Tiebreak, tiebreakDesc)).Limit(p.Limit + 1)
+}
+
+// Result trims the extra row fetched by Scope and returns the rows in list
+// order together with the cursors of the neighbouring pages. key returns the
+// values of the sort columns and the tiebreak column of a row.
+func Result[T any](p Page, cursors *Cursors, rows []T, key func(T) ([]interface{}, string)) ([]T, Neighbours, error) {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+	backwards := p.Cursor != nil && p.Cursor.Before
+	if backwards {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, Neighbours{}, nil
+	}
+
+	// Coming from a cursor means there are rows on the side we came from
+	hasNext, hasPrev := more, p.Cursor != nil || p.Offset > 0
+	if backwards {
+		hasNext, hasPrev = true, more
+	}
+
+	var neighbours Neighbours
+	var err error
+	if hasNext {
+		values, id := key(rows[len(rows)-1])
+		neighbours.Next, err = cursors.Encode(Cursor{Sort: SortKey(p.Sorts), Values: values, ID: id})
+		if err != nil {
+			return nil, Neighbours{}, err
+		}
+	}
+	if hasPrev {
+		values, id := key(rows[0])
+		neighbours.Prev, err = cursors.Encode(Cursor{Sort: SortKey(p.Sorts), Values: values, ID: id, Before: true})
+		if err != nil {
+			return nil, Neighbours{}, err
+		}
+	}
+	return rows, neighbours, nil
+}
+
+// seek matches the rows following the given position in the sort order,
+// e.g. for "a, -b, id": a > ? OR (a = ? AND b < ?) OR (a = ? AND b = ? AND id > ?)
+func seek(sorts []Sort, values []interface{}, tiebreak string, tiebreakDesc bool, id string) clause.Expression {
+	sorts = append(slices.Clone(sorts), Sort{Column: tiebreak, Desc: tiebreakDesc})
+	values = append(slices.Clone(values), id)
+
+	alternatives := make([]clause.Expression, len(sorts))
+	for i, sort := range sorts {
+		conditions := make([]clause.Expression, 0, i+1)
+		for j := range i {
+			conditions = append(conditions, clause.Eq{Column: clause.Column{Name: sorts[j].Column}, Value: values[j]})
+		}
+
+		column := clause.Column{Name: sort.Column}
+		if sort.Desc {
+			conditions = append(conditions, clause.Lt{Column: column, Value: values[i]})
+		} else {
+			conditions = append(conditions, clause.Gt{Column: column, Value: values[i]})
+		}
+		alternatives[i] = clause.And(conditions...)
+	}
+	return clause.Or(alternatives...)
+}
+
+// reverse flips the direction of every sort
+func reverse(sorts []Sort) []Sort {
+	reversed := slices.Clone(sorts)
+	for i := range reversed {
+		reversed[i].Desc = !reversed[i].Desc
+	}
+	return reversed
+}
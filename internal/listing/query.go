@@ -0,0 +1,78 @@
+package listing
+
+import (
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+// PageRequest holds the pagination query parameters of a list request
+type PageRequest struct {
+	Limit  int
+	Offset int    // only used without Cursor
+	Cursor string // continues from a next_cursor or prev_cursor
+}
+
+// InvalidCursor is the validation error responding to ErrInvalidCursor
+var InvalidCursor = response.ValidationError{Field: "cursor", Message: "Invalid cursor, or the sort order changed"}
+
+// ParsePage reads the limit, offset and cursor query parameters, accepting
+// after as an alias of cursor. Limit defaults to defaultLimit and may be at
+// most maxLimit, which keeps clients from loading whole tables. Problems are
+// returned as validation errors, to be reported together with those of the
+// other parameters.
+func ParsePage(c echo.Context, defaultLimit, maxLimit int) (PageRequest, []response.ValidationError) {
+	var req PageRequest
+	var details []response.ValidationError
+	invalid := func(field, message string) {
+		details = append(details, response.ValidationError{Field: field, Message: message})
+	}
+
+	req.Limit = defaultLimit
+	if raw := c.QueryParam("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxLimit {
+			invalid("limit", "Must be a number from 1 to "+strconv.Itoa(maxLimit))
+		}
+		req.Limit = limit
+	}
+	if raw := c.QueryParam("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			invalid("offset", "Must be a number greater than or equal to 0")
+		}
+		req.Offset = offset
+	}
+
+	req.Cursor = c.QueryParam("cursor")
+	if after := c.QueryParam("after"); after != "" {
+		if req.Cursor != "" && req.Cursor != after {
+			invalid("after", "Cannot be combined with cursor")
+		}
+		req.Cursor = after
+	}
+	if req.Cursor != "" && req.Offset > 0 {
+		invalid("offset", "Cannot be combined with cursor")
+	}
+
+	return req, details
+}
+
+// Meta returns the meta block of the page selected by the request, which
+// holds total matching rows and has the given neighbours
+func (r PageRequest) Meta(total int64, neighbours Neighbours) response.PageMeta {
+	meta := response.PageMeta{
+		Total:      total,
+		Limit:      r.Limit,
+		Cursor:     r.Cursor,
+		NextCursor: neighbours.Next,
+		PrevCursor: neighbours.Prev,
+		HasMore:    neighbours.Next != "",
+	}
+	if r.Cursor == "" {
+		meta.Offset = &r.Offset
+	}
+	return meta
+}
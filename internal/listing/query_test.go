@@ -0,0 +1,47 @@
+package listing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    PageRequest
+		invalid []string // fields reported as invalid
+	}{
+		{"", PageRequest{Limit: 20}, nil},
+		{"limit=5&offset=10", PageRequest{Limit: 5, Offset: 10}, nil},
+		{"cursor=abc", PageRequest{Limit: 20, Cursor: "abc"}, nil},
+		{"after=abc", PageRequest{Limit: 20, Cursor: "abc"}, nil},
+		{"cursor=abc&after=abc", PageRequest{Limit: 20, Cursor: "abc"}, nil},
+		{"cursor=abc&after=def", PageRequest{}, []string{"after"}},
+		{"cursor=abc&offset=1", PageRequest{}, []string{"offset"}},
+		{"limit=0", PageRequest{}, []string{"limit"}},
+		{"limit=101", PageRequest{}, []string{"limit"}},
+		{"limit=ten&offset=-1", PageRequest{}, []string{"limit", "offset"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil), httptest.NewRecorder())
+			got, details := ParsePage(c, 20, 100)
+
+			var fields []string
+			for _, detail := range details {
+				fields = append(fields, detail.Field)
+			}
+			if !slices.Equal(fields, tt.invalid) {
+				t.Fatalf("invalid fields = %v, want %v", fields, tt.invalid)
+			}
+			if tt.invalid == nil && got != tt.want {
+				t.Errorf("ParsePage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Package listing holds the building blocks of list endpoints shared by
+// features: sorting by whitelisted fields and pagination by offset or by
+// signed cursors.
+package listing
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sort orders a list by a column
+type Sort struct {
+	Field  string // name used in the sort parameter
+	Column string
+	Desc   bool
+}
+
+// ParseSort parses a comma separated sort parameter such as "name,-updated_at",
+// where a leading "-" sorts descending. fields whitelists the accepted names
+// and maps them to their columns, so only known columns ever reach the query.
+func ParseSort(raw string, fields map[string]string) ([]Sort, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var sorts []Sort
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		name, desc := strings.CutPrefix(part, "-")
+
+		column, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot sort by %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%q is given more than once", name)
+		}
+		seen[name] = true
+
+		sorts = append(sorts, Sort{Field: name, Column: column, Desc: desc})
+	}
+	return sorts, nil
+}
+
+// Order returns a GORM scope ordering by the sorts and then by the tiebreak
+// column, which must be unique so that the order is deterministic
+func Order(sorts []Sort, tiebreak string) func(*gorm.DB) *gorm.DB {
+	return orderBy(sorts, tiebreak, false)
+}
+
+func orderBy(sorts []Sort, tiebreak string, tiebreakDesc bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		columns := make([]clause.OrderByColumn, 0, len(sorts)+1)
+		for _, sort := range sorts {
+			columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: sort.Column}, Desc: sort.Desc})
+		}
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: tiebreak}, Desc: tiebreakDesc})
+		return db.Order(clause.OrderBy{Columns: columns})
+	}
+}
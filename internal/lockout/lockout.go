@@ -0,0 +1,147 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Entry is the failure state tracked for one account or IP address
+type Entry struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+// Store persists failure counters by key.
+type Store interface {
+	// Get returns the entry for key, or a zero Entry if there is none.
+	Get(ctx context.Context, key string) (Entry, error)
+
+	// RecordFailure atomically applies policy to the entry for key and returns the result.
+	RecordFailure(ctx context.Context, key string, policy Policy, now time.Time) (Entry, error)
+
+	// Delete forgets the failures recorded for key.
+	Delete(ctx context.Context, key string) error
+}
+
+// Policy locks a key out once MaxFailures is reached. The lockout starts at
+// BaseDelay and doubles with every further failure up to MaxDelay. Failures
+// are forgotten after Window without a new one.
+type Policy struct {
+	MaxFailures int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Window      time.Duration
+}
+
+// fail returns the entry after one more failure at now
+func (p Policy) fail(e Entry, now time.Time) Entry {
+	if now.Sub(e.LastFailureAt) > p.Window {
+		e = Entry{}
+	}
+	e.Failures++
+	e.LastFailureAt = now
+
+	if excess := e.Failures - p.MaxFailures; excess >= 0 {
+		delay := p.MaxDelay
+		if excess < 32 && p.BaseDelay<<excess < p.MaxDelay {
+			delay = p.BaseDelay << excess
+		}
+		e.LockedUntil = now.Add(delay)
+	}
+	return e
+}
+
+// LockedError is returned while an account or IP address is locked out
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("locked out, retry after %s", e.RetryAfter)
+}
+
+// Limiter counts failed logins per account and per IP address. The IP limit
+// catches guessing across many accounts, so it is usually set higher.
+type Limiter struct {
+	store   Store
+	prefix  string // keeps the counters of limiters sharing a store apart
+	account Policy
+	ip      Policy
+}
+
+func New(store Store, account, ip Policy) *Limiter {
+	return &Limiter{store: store, account: account, ip: ip}
+}
+
+// NewNamed returns a limiter for an action other than login, such as sending
+// mail, with counters of its own in the store
+func NewNamed(store Store, name string, account, ip Policy) *Limiter {
+	return &Limiter{store: store, prefix: name + ":", account: account, ip: ip}
+}
+
+// Check returns a *LockedError if the account or the IP address is locked out
+func (l *Limiter) Check(ctx context.Context, account, ip string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range []string{l.accountKey(account), l.ipKey(ip)} {
+		entry, err := l.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if wait := entry.LockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Attempt counts an attempt at a throttled action, unless the account or the
+// IP address is locked out, in which case a *LockedError is returned. Every
+// attempt counts, whether or not it succeeds.
+func (l *Limiter) Attempt(ctx context.Context, account, ip string) error {
+	if err := l.Check(ctx, account, ip); err != nil {
+		return err
+	}
+	return l.Fail(ctx, account, ip)
+}
+
+// Fail records a failed attempt for the account and the IP address
+func (l *Limiter) Fail(ctx context.Context, account, ip string) error {
+	now := time.Now()
+	if _, err := l.store.RecordFailure(ctx, l.accountKey(account), l.account, now); err != nil {
+		return err
+	}
+	_, err := l.store.RecordFailure(ctx, l.ipKey(ip), l.ip, now)
+	return err
+}
+
+// Succeed clears the failures of the account after a successful login. The
+// IP address keeps its failures, otherwise an attacker could reset them by
+// logging into an account of their own.
+func (l *Limiter) Succeed(ctx context.Context, account string) error {
+	return l.store.Delete(ctx, l.accountKey(account))
+}
+
+// UnlockAccount lifts a lockout of the account and clears its failures
+func (l *Limiter) UnlockAccount(ctx context.Context, account string) error {
+	return l.store.Delete(ctx, l.accountKey(account))
+}
+
+// UnlockIP lifts a lockout of the IP address and clears its failures
+func (l *Limiter) UnlockIP(ctx context.Context, ip string) error {
+	return l.store.Delete(ctx, l.ipKey(ip))
+}
+
+func (l *Limiter) accountKey(account string) string {
+	return l.prefix + "account:" + account
+}
+
+func (l *Limiter) ipKey(ip string) string {
+	return l.prefix + "ip:" + ip
+}
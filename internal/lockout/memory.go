@@ -0,0 +1,49 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failure counters in process memory. It is intended for
+// development and single-instance deployments; counters are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries[key], nil
+}
+
+func (s *MemoryStore) RecordFailure(_ context.Context, key string, policy Policy, now time.Time) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop entries whose failures have been forgotten
+	for k, e := range s.entries {
+		if now.Sub(e.LastFailureAt) > policy.Window && now.After(e.LockedUntil) {
+			delete(s.entries, k)
+		}
+	}
+
+	entry := policy.fail(s.entries[key], now)
+	s.entries[key] = entry
+	return entry, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,81 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginFailure is the persisted failure state of an account or IP address
+type LoginFailure struct {
+	Key           string    `gorm:"type:varchar(320);primaryKey"`
+	Failures      int       `gorm:"not null"`
+	LastFailureAt time.Time `gorm:"not null;index"`
+	LockedUntil   *time.Time
+}
+
+// TableName returns the table name for the LoginFailure model
+func (LoginFailure) TableName() string {
+	return "login_failures"
+}
+
+// PostgresStore persists failure counters so they are shared by every instance.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (Entry, error) {
+	var row LoginFailure
+	err := s.db.WithContext(ctx).Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return row.entry(), nil
+}
+
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, policy Policy, now time.Time) (Entry, error) {
+	var entry Entry
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the row so concurrent failures are all counted
+		var row LoginFailure
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		entry = policy.fail(row.entry(), now)
+		row = LoginFailure{
+			Key:           key,
+			Failures:      entry.Failures,
+			LastFailureAt: entry.LastFailureAt,
+		}
+		if !entry.LockedUntil.IsZero() {
+			row.LockedUntil = &entry.LockedUntil
+		}
+
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error
+	})
+	return entry, err
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&LoginFailure{}).Error
+}
+
+func (row LoginFailure) entry() Entry {
+	entry := Entry{Failures: row.Failures, LastFailureAt: row.LastFailureAt}
+	if row.LockedUntil != nil {
+		entry.LockedUntil = *row.LockedUntil
+	}
+	return entry
+}
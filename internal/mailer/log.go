@@ -0,0 +1,27 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+)
+
+// LogMailer logs emails instead of sending them, for development. Message
+// bodies may contain tokens, so it must not be used in production.
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	logger.Info().
+		Str("from", m.from).
+		Str("to", msg.To).
+		Str("subject", msg.Subject).
+		Str("body", msg.Body).
+		Msg("Email")
+	return nil
+}
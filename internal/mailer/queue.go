@@ -0,0 +1,111 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+)
+
+// sendTimeout bounds the delivery of a single queued message
+const sendTimeout = 30 * time.Second
+
+// ErrQueueFull is returned when a message cannot be queued without waiting
+var ErrQueueFull = errors.New("mail queue is full")
+
+// ErrQueueClosed is returned for messages queued after Close
+var ErrQueueClosed = errors.New("mail queue is closed")
+
+// Queue delivers messages in the background with a fixed number of workers,
+// so that responses do not wait for the mail server and bursts of requests
+// cannot open any number of connections to it.
+type Queue struct {
+	mailer   Mailer
+	messages chan Message
+	workers  sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+	ctx    context.Context // cancelled when Close gives up waiting
+	cancel context.CancelFunc
+}
+
+// NewQueue starts workers delivering through m, holding up to size messages
+// waiting for a worker
+func NewQueue(m Mailer, workers, size int) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		mailer:   m,
+		messages: make(chan Message, size),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	q.workers.Add(workers)
+	for range workers {
+		go q.work()
+	}
+	return q
+}
+
+// Enqueue schedules the message for delivery. It never waits: if every worker
+// is busy and the queue is full, ErrQueueFull is returned.
+func (q *Queue) Enqueue(msg Message) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting messages and waits until the queued ones have been
+// delivered. If ctx is done first, deliveries still in progress are
+// cancelled and the messages left in the queue are dropped.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.messages)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for msg := range q.messages {
+		if q.ctx.Err() != nil {
+			continue // Close gave up, drop what is left
+		}
+
+		ctx, cancel := context.WithTimeout(q.ctx, sendTimeout)
+		if err := q.mailer.Send(ctx, msg); err != nil {
+			logger.Error().Err(err).Str("subject", msg.Subject).Msg("Failed to send email")
+		}
+		cancel()
+	}
+}
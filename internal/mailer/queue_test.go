@@ -0,0 +1,98 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMailer collects sent messages, waiting for release first if set
+type recordingMailer struct {
+	release chan struct{}
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg Message) error {
+	if m.release != nil {
+		select {
+		case <-m.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *recordingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+func TestQueueDrainsOnClose(t *testing.T) {
+	m := &recordingMailer{}
+	q := NewQueue(m, 2, 10)
+	for range 10 {
+		if err := q.Enqueue(Message{To: "user@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.count(); got != 10 {
+		t.Errorf("sent %d messages, want 10", got)
+	}
+	if err := q.Enqueue(Message{}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Enqueue after Close = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	m := &recordingMailer{release: make(chan struct{})}
+	q := NewQueue(m, 1, 1)
+
+	// The worker holds one message and the queue the next
+	var err error
+	for range 3 {
+		if err = q.Enqueue(Message{}); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Enqueue = %v, want ErrQueueFull", err)
+	}
+
+	close(m.release)
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.count(); got != 2 {
+		t.Errorf("sent %d messages, want 2", got)
+	}
+}
+
+func TestQueueCloseDeadline(t *testing.T) {
+	m := &recordingMailer{release: make(chan struct{})}
+	q := NewQueue(m, 1, 10)
+	for range 3 {
+		if err := q.Enqueue(Message{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	apperrors "github.com/SuperIntelligence-Labs/go-backend-template/internal/errors"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// APIKeyAuthenticator resolves a raw API key to the claims of its owner
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*JWTClaims, error)
+}
+
+// APIKeyMiddleware authenticates requests that carry an API key in the
+// X-API-Key header or as "Authorization: ApiKey <key>". Requests without an
+// API key are handed to fallback, typically JWTMiddleware, so a route can
+// accept both; a nil fallback rejects them.
+func APIKeyMiddleware(authenticator APIKeyAuthenticator, fallback echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		var viaFallback echo.HandlerFunc
+		if fallback != nil {
+			viaFallback = fallback(next)
+		}
+
+		return func(c echo.Context) error {
+			key := extractAPIKey(c.Request().Header)
+			if key == "" {
+				if viaFallback == nil {
+					return response.ErrUnauthorized("Invalid or missing API key")
+				}
+				return viaFallback(c)
+			}
+
+			claims, err := authenticator.AuthenticateAPIKey(c.Request().Context(), key)
+			if err != nil {
+				if errors.Is(err, apperrors.ErrUnauthorized) {
+					return response.ErrUnauthorized("Invalid or expired API key")
+				}
+				return response.ErrInternalError(err)
+			}
+
+			setClaims(c, claims)
+			return next(c)
+		}
+	}
+}
+
+func extractAPIKey(header http.Header) string {
+	if key := header.Get(HeaderAPIKey); key != "" {
+		return key
+	}
+
+	scheme, key, ok := strings.Cut(header.Get(echo.HeaderAuthorization), " ")
+	if ok && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// setClaims stores claims the same way echo-jwt stores a parsed token, so
+// GetClaims works regardless of how the request was authenticated.
+func setClaims(c echo.Context, claims *JWTClaims) {
+	c.Set("user", &jwt.Token{Claims: claims, Valid: true})
+}
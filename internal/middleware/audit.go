@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+// Audit stores the caller in the request context so that services can record
+// audit entries. It must run after authentication. Every request made with
+// an impersonation token is recorded as well; a nil recorder disables that.
+func Audit(recorder audit.Recorder) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := GetClaims(c)
+			if err != nil {
+				return err
+			}
+
+			caller := audit.Request{
+				ActorID:   &claims.UserID,
+				RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+				IP:        c.RealIP(),
+			}
+			if claims.Impersonated() {
+				caller.ImpersonatorID = &claims.Actor.UserID
+			}
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(audit.WithRequest(req.Context(), caller)))
+
+			if !claims.Impersonated() || recorder == nil {
+				return next(c)
+			}
+
+			err = next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				status = errorStatus(err)
+			}
+
+			// The entry is written even if the request timed out
+			ctx := context.WithoutCancel(c.Request().Context())
+			recordErr := recorder.Record(ctx, &audit.Entry{
+				Action:       "impersonation.request",
+				ResourceType: "request",
+				Details: audit.JSON{
+					"method": req.Method,
+					"path":   req.URL.Path,
+					"status": status,
+				},
+			})
+			if recordErr != nil {
+				logger.Error().Err(recordErr).Str("request_id", caller.RequestID).Msg("Failed to record impersonated request")
+			}
+
+			return err
+		}
+	}
+}
+
+// RejectImpersonation refuses requests made with an impersonation token, for
+// routes that change how the user signs in
+func RejectImpersonation(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims, err := GetClaims(c)
+		if err != nil {
+			return err
+		}
+		if claims.Impersonated() {
+			return response.ErrForbidden("Not allowed while impersonating a user")
+		}
+		return next(c)
+	}
+}
+
+// errorStatus returns the status code the error handler will respond with
+func errorStatus(err error) int {
+	var appErr *response.AppError
+	if errors.As(err, &appErr) && appErr.StatusCode != 0 {
+		return appErr.StatusCode
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
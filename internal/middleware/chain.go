@@ -0,0 +1,13 @@
+package middleware
+
+import "github.com/labstack/echo/v4"
+
+// Chain combines middleware into one, applied in the given order
+func Chain(middleware ...echo.MiddlewareFunc) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
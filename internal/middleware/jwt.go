@@ -2,40 +2,122 @@ package middleware
 
 import (
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/keyring"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/revocation"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeAPIKey  = "api_key" // claims built from an API key, never a signed token
+
+	// TokenTypeMFAPending is issued after the password step of a login for an
+	// account with MFA enabled. It is only accepted by the MFA verification route.
+	TokenTypeMFAPending = "mfa_pending"
 )
 
 type JWTClaims struct {
 	UserID    uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
 	Role      string    `json:"role,omitempty"`
+	TenantID  string    `json:"tid,omitempty"`
+	SessionID string    `json:"sid,omitempty"` // token family of the login that issued the token
+	Scopes    []string  `json:"scopes,omitempty"`
 	TokenType string    `json:"token_type"`
+	Actor     *Actor    `json:"act,omitempty"` // set on impersonation tokens
 	jwt.RegisteredClaims
 }
 
-// JWTMiddleware returns a configured JWT middleware with custom error handling
-func JWTMiddleware(secretKey string) echo.MiddlewareFunc {
+// Actor identifies the admin acting as the token's user, following the act
+// claim of RFC 8693
+type Actor struct {
+	UserID   uuid.UUID `json:"sub"`
+	Username string    `json:"username,omitempty"`
+}
+
+// Impersonated reports whether the token was issued to an admin acting as the user
+func (c *JWTClaims) Impersonated() bool {
+	return c.Actor != nil
+}
+
+// JWTMiddleware returns a configured JWT middleware with custom error handling.
+// Tokens are verified with the keyring key named by their kid header. Tokens
+// revoked in the given store are rejected; a nil store disables the check.
+// Only access tokens are accepted unless other token types are given.
+func JWTMiddleware(keys *keyring.Keyring, revocations revocation.Store, tokenTypes ...string) echo.MiddlewareFunc {
+	if len(tokenTypes) == 0 {
+		tokenTypes = []string{TokenTypeAccess}
+	}
+
 	config := echojwt.Config{
-		SigningKey:  []byte(secretKey),
 		TokenLookup: "header:Authorization:Bearer ",
 
+		ParseTokenFunc: func(c echo.Context, auth string) (interface{}, error) {
+			claims := new(JWTClaims)
+			token, err := keys.Parse(auth, claims)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(tokenTypes, claims.TokenType) {
+				return nil, fmt.Errorf("unexpected token type: %s", claims.TokenType)
+			}
+			return token, nil
+		},
+
 		// Custom error handler that uses your AppError format
 		ErrorHandler: func(c echo.Context, err error) error {
 			return response.ErrUnauthorized("Invalid or missing authentication token")
 		},
+	}
 
-		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(JWTClaims)
-		},
+	authenticate := echojwt.WithConfig(config)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return authenticate(rejectRevoked(revocations, next))
 	}
+}
+
+// rejectRevoked consults the revocation store after the token signature has been verified
+func rejectRevoked(revocations revocation.Store, next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if revocations == nil {
+			return next(c)
+		}
+
+		claims, err := GetClaims(c)
+		if err != nil {
+			return err
+		}
+
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
 
-	return echojwt.WithConfig(config)
+		ids := []string{claims.ID}
+		if claims.SessionID != "" {
+			ids = append(ids, claims.SessionID)
+		}
+
+		revoked, err := revocations.IsRevoked(c.Request().Context(), ids, claims.UserID, issuedAt)
+		if err != nil {
+			return response.ErrInternalError(err)
+		}
+		if revoked {
+			return response.ErrUnauthorized("Authentication token has been revoked")
+		}
+
+		return next(c)
+	}
 }
 
 // GetClaims extracts JWT claims from the Echo context
@@ -54,21 +136,14 @@ func GetClaims(c echo.Context) (*JWTClaims, error) {
 }
 
 // ValidateRefreshToken validates a refresh token and returns claims
-func ValidateRefreshToken(tokenString, secretKey string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing algorithm to prevent algorithm confusion attacks
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
-
+func ValidateRefreshToken(tokenString string, keys *keyring.Keyring) (*JWTClaims, error) {
+	token, err := keys.Parse(tokenString, &JWTClaims{})
 	if err != nil {
 		return nil, err
 	}
 
 	claims, ok := token.Claims.(*JWTClaims)
-	if !ok || !token.Valid || claims.TokenType != "refresh" {
+	if !ok || !token.Valid || claims.TokenType != TokenTypeRefresh {
 		return nil, fmt.Errorf("invalid token")
 	}
 
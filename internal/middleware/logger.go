@@ -7,6 +7,7 @@ import (
 	"github.com/rs/zerolog"
 
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/logger"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
 )
 
 func Zerolog() echo.MiddlewareFunc {
@@ -56,6 +57,17 @@ func Zerolog() echo.MiddlewareFunc {
 				event = event.Str("request_id", id)
 			}
 
+			if id, ok := tenant.FromContext(req.Context()); ok {
+				event = event.Str("tenant_id", id)
+			}
+
+			if claims, err := GetClaims(c); err == nil && claims.Impersonated() {
+				event = event.
+					Bool("impersonated", true).
+					Str("user_id", claims.UserID.String()).
+					Str("impersonator_id", claims.Actor.UserID.String())
+			}
+
 			event.Msg("HTTP request")
 			return nil
 		}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+)
+
+// PermissionWildcard grants every permission; "items:*" grants every items action.
+const PermissionWildcard = "*"
+
+const permissionsKey = "permissions"
+
+// PermissionResolver returns the permissions granted to a role
+type PermissionResolver interface {
+	RolePermissions(ctx context.Context, role string) ([]string, error)
+}
+
+// LoadPermissions resolves the caller's permissions once per request so that
+// RequirePermission and HasPermission can check them. It must run after
+// authentication. API key requests are further limited to the key's scopes.
+func LoadPermissions(resolver PermissionResolver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims, err := GetClaims(c)
+			if err != nil {
+				return err
+			}
+
+			granted, err := resolver.RolePermissions(c.Request().Context(), claims.Role)
+			if err != nil {
+				return response.ErrInternalError(err)
+			}
+
+			if claims.TokenType == TokenTypeAPIKey {
+				granted = intersectPermissions(granted, claims.Scopes)
+			}
+
+			c.Set(permissionsKey, granted)
+			return next(c)
+		}
+	}
+}
+
+// RequirePermission is a middleware that checks if the caller has all of the given permissions
+func RequirePermission(required ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			for _, permission := range required {
+				if !HasPermission(c, permission) {
+					return response.ErrForbidden("Insufficient permissions")
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// HasPermission reports whether the caller was granted the permission
+func HasPermission(c echo.Context, permission string) bool {
+	granted, _ := c.Get(permissionsKey).([]string)
+	for _, g := range granted {
+		if permissionMatches(g, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionMatches reports whether a granted permission covers the required one
+func permissionMatches(granted, required string) bool {
+	if granted == PermissionWildcard || granted == required {
+		return true
+	}
+	resource, action, ok := strings.Cut(granted, ":")
+	return ok && action == PermissionWildcard && strings.HasPrefix(required, resource+":")
+}
+
+// intersectPermissions keeps the scopes that are covered by the granted permissions
+func intersectPermissions(granted, scopes []string) []string {
+	var result []string
+	for _, scope := range scopes {
+		for _, g := range granted {
+			if permissionMatches(g, scope) {
+				result = append(result, scope)
+				break
+			}
+		}
+	}
+	return result
+}
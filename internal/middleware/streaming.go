@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// streamingRoutes holds the method and path of every route marked with Streaming
+var streamingRoutes sync.Map
+
+// Streaming marks a route as streaming its response. The request timeout
+// buffers whole responses, so the server skips it for these routes.
+func Streaming(route *echo.Route) *echo.Route {
+	streamingRoutes.Store(route.Method+" "+route.Path, true)
+	return route
+}
+
+// IsStreaming reports whether the route handling c was marked with Streaming
+func IsStreaming(c echo.Context) bool {
+	_, ok := streamingRoutes.Load(c.Request().Method + " " + c.Path())
+	return ok
+}
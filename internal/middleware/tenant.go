@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/tenant"
+)
+
+// ResolveTenant stores the tenant of the request in the request context.
+// Authenticated callers are bound to the tid claim of their token; a tenant
+// named by the header or subdomain must then match it. Unauthenticated
+// requests, such as registration, use the header or subdomain, falling back
+// to the default tenant. Tokens issued without a tid belong to the default.
+// Running it again after authentication is safe and re-checks the claim.
+func ResolveTenant(cfg config.TenantConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			requested, err := requestedTenant(c, cfg)
+			if err != nil {
+				return err
+			}
+
+			id := requested
+			if claims, err := GetClaims(c); err == nil {
+				id = claims.TenantID
+				if id == "" {
+					id = cfg.Default
+				}
+				if requested != "" && requested != id {
+					return response.ErrForbidden("Access to this tenant is not allowed")
+				}
+			}
+			if id == "" {
+				id = cfg.Default
+			}
+
+			req := c.Request()
+			c.SetRequest(req.WithContext(tenant.WithID(req.Context(), id)))
+			return next(c)
+		}
+	}
+}
+
+// requestedTenant returns the tenant named by the header or the subdomain, if any
+func requestedTenant(c echo.Context, cfg config.TenantConfig) (string, error) {
+	fromHeader := strings.ToLower(strings.TrimSpace(c.Request().Header.Get(cfg.Header)))
+	if fromHeader != "" && !tenant.Valid(fromHeader) {
+		return "", response.ErrBadRequest("Invalid tenant ID", nil)
+	}
+
+	fromHost := subdomain(c.Request().Host, cfg.BaseDomain)
+	if fromHost != "" && !tenant.Valid(fromHost) {
+		return "", response.ErrBadRequest("Invalid tenant ID", nil)
+	}
+
+	if fromHeader != "" && fromHost != "" && fromHeader != fromHost {
+		return "", response.ErrBadRequest("Tenant header does not match the host", nil)
+	}
+	if fromHeader != "" {
+		return fromHeader, nil
+	}
+	return fromHost, nil
+}
+
+// subdomain returns the label in front of the base domain, e.g. "acme" for
+// acme.example.com, or "" if the host is not a direct subdomain of it
+func subdomain(host, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	label, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(baseDomain))
+	if !ok || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
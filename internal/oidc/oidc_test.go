@@ -0,0 +1,488 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
+)
+
+const (
+	testClientID     = "test-client"
+	testClientSecret = "test-secret"
+	testRedirectURL  = "https://app.example.com/callback"
+	testKeyID        = "test-key"
+)
+
+// mockProvider is a minimal OpenID Connect provider. Its authorization
+// endpoint logs every request in right away and redirects with a code; the
+// token endpoint redeems the code for an ID token built by idToken.
+type mockProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	// discovery overrides the discovery document when set
+	discovery map[string]string
+
+	mu     sync.Mutex
+	logins map[string]login // by code
+
+	// idToken builds the ID token returned for a login
+	idToken func(l login) string
+}
+
+// login is a pending authorization of the mock provider
+type login struct {
+	challenge string
+	nonce     string
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockProvider{t: t, key: key, logins: make(map[string]login)}
+	p.idToken = func(l login) string {
+		return p.sign(p.claims(l.nonce))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.handleDiscovery)
+	mux.HandleFunc("GET /authorize", p.handleAuthorize)
+	mux.HandleFunc("POST /token", p.handleToken)
+	mux.HandleFunc("GET /jwks", p.handleJWKS)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *mockProvider) config() config.OIDCConfig {
+	return config.OIDCConfig{
+		Issuer:       p.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}
+}
+
+func (p *mockProvider) handleDiscovery(w http.ResponseWriter, _ *http.Request) {
+	document := p.discovery
+	if document == nil {
+		document = map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		}
+	}
+	writeJSON(w, http.StatusOK, document)
+}
+
+func (p *mockProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testClientID || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString(p.t)
+	p.mu.Lock()
+	p.logins[code] = login{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	p.mu.Unlock()
+
+	redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+func (p *mockProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	p.mu.Lock()
+	l, ok := p.logins[r.PostForm.Get("code")]
+	delete(p.logins, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	// RFC 7636, section 4.6
+	if !ok || Challenge(r.PostForm.Get("code_verifier")) != l.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     p.idToken(l),
+	})
+}
+
+func (p *mockProvider) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	public, err := p.key.PublicKey.ECDH()
+	if err != nil {
+		p.t.Error(err)
+		return
+	}
+	point := public.Bytes() // 0x04 || X || Y
+	enc := base64.RawURLEncoding
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "EC",
+			"kid": testKeyID,
+			"use": "sig",
+			"crv": "P-256",
+			"x":   enc.EncodeToString(point[1:33]),
+			"y":   enc.EncodeToString(point[33:]),
+		}},
+	})
+}
+
+// claims returns valid ID token claims for the nonce
+func (p *mockProvider) claims(nonce string) *Claims {
+	now := time.Now()
+	return &Claims{
+		Nonce:         nonce,
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.server.URL,
+			Subject:   "user-123",
+			Audience:  jwt.ClaimStrings{testClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+}
+
+// sign signs claims with the provider's published key
+func (p *mockProvider) sign(claims *Claims) string {
+	return signWith(p.t, p.key, claims)
+}
+
+func signWith(t *testing.T, key *ecdsa.PrivateKey, claims *Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// authorize sends the user agent to the authorization URL and returns the
+// code of the redirect back to the client
+func authorize(t *testing.T, authURL string) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testRedirectURL {
+		t.Fatalf("redirected to %s, want %s", got, testRedirectURL)
+	}
+	return location.Query().Get("code")
+}
+
+func newProvider(t *testing.T, mock *mockProvider) *Provider {
+	t.Helper()
+
+	provider, err := New(context.Background(), mock.config(), mock.server.Client())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return provider
+}
+
+func randomString(t *testing.T) string {
+	t.Helper()
+
+	s, err := RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestNewDiscovery(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newProvider(t, mock)
+
+	if got := provider.Issuer(); got != mock.server.URL {
+		t.Errorf("Issuer() = %q, want %q", got, mock.server.URL)
+	}
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state", "nonce", Challenge("verifier")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := authURL.Scheme + "://" + authURL.Host + authURL.Path; got != mock.server.URL+"/authorize" {
+		t.Errorf("authorization endpoint = %s, want %s/authorize", got, mock.server.URL)
+	}
+
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        Challenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for param, value := range want {
+		if got := authURL.Query().Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+}
+
+func TestNewDiscoveryRejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		discovery func(issuer string) map[string]string
+	}{
+		{
+			name: "issuer mismatch",
+			discovery: func(issuer string) map[string]string {
+				return map[string]string{
+					"issuer":                 "https://evil.example.com",
+					"authorization_endpoint": issuer + "/authorize",
+					"token_endpoint":         issuer + "/token",
+					"jwks_uri":               issuer + "/jwks",
+				}
+			},
+		},
+		{
+			name: "missing token endpoint",
+			discovery: func(issuer string) map[string]string {
+				return map[string]string{
+					"issuer":                 issuer,
+					"authorization_endpoint": issuer + "/authorize",
+					"jwks_uri":               issuer + "/jwks",
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockProvider(t)
+			mock.discovery = tt.discovery(mock.server.URL)
+
+			if _, err := New(context.Background(), mock.config(), mock.server.Client()); err == nil {
+				t.Fatal("New() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newProvider(t, mock)
+
+	nonce := randomString(t)
+	verifier := randomString(t)
+	code := authorize(t, provider.AuthCodeURL(randomString(t), nonce, Challenge(verifier)))
+
+	rawIDToken, err := provider.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	claims, err := provider.Verify(context.Background(), rawIDToken, nonce)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "jane@example.com" || !claims.EmailVerified {
+		t.Errorf("Verify() claims = %+v", claims)
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newProvider(t, mock)
+
+	code := authorize(t, provider.AuthCodeURL(randomString(t), randomString(t), Challenge(randomString(t))))
+
+	if _, err := provider.Exchange(context.Background(), code, randomString(t)); err == nil {
+		t.Fatal("Exchange() with another verifier succeeded, want error")
+	}
+}
+
+func TestVerifyRejected(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newProvider(t, mock)
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const nonce = "expected-nonce"
+	tests := []struct {
+		name  string
+		token func() string
+	}{
+		{
+			name: "bad signature",
+			token: func() string {
+				return signWith(t, otherKey, mock.claims(nonce))
+			},
+		},
+		{
+			name: "unsigned",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, mock.claims(nonce))
+				signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return signed
+			},
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.Audience = jwt.ClaimStrings{"other-client"}
+				return mock.sign(claims)
+			},
+		},
+		{
+			name: "multiple audiences without azp",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.Audience = jwt.ClaimStrings{testClientID, "other-client"}
+				return mock.sign(claims)
+			},
+		},
+		{
+			name: "azp of another client",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.AuthorizedParty = "other-client"
+				return mock.sign(claims)
+			},
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.Issuer = "https://evil.example.com"
+				return mock.sign(claims)
+			},
+		},
+		{
+			name: "wrong nonce",
+			token: func() string {
+				return mock.sign(mock.claims("other-nonce"))
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+				claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * leeway))
+				return mock.sign(claims)
+			},
+		},
+		{
+			name: "missing expiry",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.ExpiresAt = nil
+				return mock.sign(claims)
+			},
+		},
+		{
+			name: "missing subject",
+			token: func() string {
+				claims := mock.claims(nonce)
+				claims.Subject = ""
+				return mock.sign(claims)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.Verify(context.Background(), tt.token(), nonce)
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Verify() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAcceptsMatchingAuthorizedParty(t *testing.T) {
+	mock := newMockProvider(t)
+	provider := newProvider(t, mock)
+
+	claims := mock.claims("nonce")
+	claims.Audience = jwt.ClaimStrings{testClientID, "other-client"}
+	claims.AuthorizedParty = testClientID
+
+	if _, err := provider.Verify(context.Background(), mock.sign(claims), "nonce"); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	if got := Challenge(verifier); got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("Challenge() = %q", got)
+	}
+	if strings.ContainsAny(randomString(t), "+/=") {
+		t.Error("RandomString() is not base64url without padding")
+	}
+}
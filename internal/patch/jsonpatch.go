@@ -0,0 +1,336 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// operation is a single JSON Patch operation with its pointers parsed
+type operation struct {
+	Op    string
+	Path  []string
+	From  []string
+	Value interface{}
+}
+
+// Apply applies a JSON Patch to doc. The operations are applied in order and
+// the patch fails as a whole if any of them fails.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+	ops, err := parseOperations(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		target, err = op.apply(target)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+// parseOperations decodes and checks the operations of a patch
+func parseOperations(patch []byte) ([]operation, error) {
+	var raw []map[string]json.RawMessage
+	if err := decode(patch, &raw); err != nil {
+		return nil, fmt.Errorf("%w: must be an array of operations: %w", ErrMalformed, err)
+	}
+
+	ops := make([]operation, len(raw))
+	for i, fields := range raw {
+		malformed := func(reason string) error {
+			return fmt.Errorf("%w: operation %d: %s", ErrMalformed, i, reason)
+		}
+
+		op := &ops[i]
+		if err := json.Unmarshal(fields["op"], &op.Op); err != nil {
+			return nil, malformed(`"op" must be a string`)
+		}
+		var err error
+		if op.Path, err = member(fields, "path"); err != nil {
+			return nil, malformed(err.Error())
+		}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			value, ok := fields["value"]
+			if !ok {
+				return nil, malformed(`"value" is required`)
+			}
+			if err := decode(value, &op.Value); err != nil {
+				return nil, malformed(`invalid "value"`)
+			}
+		case "move", "copy":
+			if op.From, err = member(fields, "from"); err != nil {
+				return nil, malformed(err.Error())
+			}
+		case "remove":
+		default:
+			return nil, malformed(fmt.Sprintf("unknown op %q", op.Op))
+		}
+	}
+	return ops, nil
+}
+
+// member parses the JSON Pointer held by a member of an operation
+func member(fields map[string]json.RawMessage, name string) ([]string, error) {
+	var pointer string
+	if err := json.Unmarshal(fields[name], &pointer); err != nil {
+		return nil, fmt.Errorf("%q must be a string", name)
+	}
+	return parsePointer(pointer)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped reference
+// tokens; the empty pointer refers to the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// apply applies the operation to doc and returns the resulting document
+func (op operation) apply(doc interface{}) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return add(doc, op.Path, op.Value)
+	case "remove":
+		doc, _, err := remove(doc, op.Path)
+		return doc, err
+	case "replace":
+		if len(op.Path) == 0 {
+			return op.Value, nil
+		}
+		doc, _, err := remove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, op.Value)
+	case "move":
+		if len(op.Path) > len(op.From) && slices.Equal(op.Path[:len(op.From)], op.From) {
+			return nil, fmt.Errorf("%w: cannot move a value into itself", ErrNotApplicable)
+		}
+		doc, value, err := remove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, value)
+	case "copy":
+		value, err := get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, deepCopy(value))
+	case "test":
+		value, err := get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(value, op.Value) {
+			return nil, fmt.Errorf("%w: test failed", ErrNotApplicable)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrMalformed, op.Op)
+}
+
+// get returns the value at path
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := index(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, notFound(token)
+		}
+	}
+	return doc, nil
+}
+
+// add inserts value at path, replacing an existing object member. In arrays
+// it inserts before the index, or appends for "-".
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	return update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			i, err := index(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(node, i, value), nil
+		}
+		return nil, notFound(token)
+	}, value)
+}
+
+// remove deletes the value at path, which must exist, and returns it
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", ErrNotApplicable)
+	}
+
+	var removed interface{}
+	doc, err := update(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, notFound(token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := index(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return slices.Delete(node, i, i+1), nil
+		}
+		return nil, notFound(token)
+	}, nil)
+	return doc, removed, err
+}
+
+// update walks to the container holding the last token of path, lets change
+// modify it and stores the modified container back into its parent. An empty
+// path replaces the whole document with root.
+func update(doc interface{}, path []string, change func(parent interface{}, token string) (interface{}, error), root interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return root, nil
+	}
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+
+	token := path[0]
+	child, err := get(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = update(child, path[1:], change, root)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[token] = child
+	case []interface{}:
+		i, _ := index(token, len(node)-1)
+		node[i] = child
+	}
+	return doc, nil
+}
+
+// index parses an array index token, which must not exceed last
+func index(token string, last int) (int, error) {
+	// Leading zeros are not allowed, RFC 6901 section 4
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, notFound(token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > last {
+		return 0, notFound(token)
+	}
+	return i, nil
+}
+
+func notFound(token string) error {
+	return fmt.Errorf("%w: path element %q not found", ErrNotApplicable, token)
+}
+
+// equal compares JSON values as RFC 6902 section 4.6 describes: numbers by
+// value, objects regardless of member order
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// deepCopy copies objects and arrays, so a copied value can be changed by
+// later operations without affecting its source
+func deepCopy(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			copied[key] = deepCopy(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, v := range value {
+			copied[i] = deepCopy(v)
+		}
+		return copied
+	default:
+		return value
+	}
+}
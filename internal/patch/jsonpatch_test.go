@@ -0,0 +1,271 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc, patch string
+		want       string // unused when wantErr is set
+		wantErr    error
+	}{
+		// The examples of RFC 6902, appendix A
+		{
+			name:  "A.1 adding an object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "A.2 adding an array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "A.3 removing an object member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "A.4 removing an array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "A.5 replacing a value",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "A.6 moving a value",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "A.7 moving an array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "A.8 testing a value, success",
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:    "A.9 testing a value, error",
+			doc:     `{"baz":"qux"}`,
+			patch:   `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:  "A.10 adding a nested member object",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "A.11 ignoring unrecognized elements",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:    "A.12 adding to a nonexistent target",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: ErrNotApplicable,
+		},
+		// A.13, an operation with a duplicate member, is decoded by encoding/json
+		// keeping the last member
+		{
+			name:  "A.14 ~ escape ordering",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10}]`,
+			want:  `{"/":9,"~1":10}`,
+		},
+		{
+			name:    "A.15 comparing strings and numbers",
+			doc:     `{"/":9,"~1":10}`,
+			patch:   `[{"op":"test","path":"/~01","value":"10"}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:  "A.16 adding an array value",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+
+		// Pointer escaping, RFC 6901
+		{
+			name:  "~1 escapes a slash",
+			doc:   `{}`,
+			patch: `[{"op":"add","path":"/a~1b","value":1}]`,
+			want:  `{"a/b":1}`,
+		},
+		{
+			name:  "~0 escapes a tilde",
+			doc:   `{"m~n":1}`,
+			patch: `[{"op":"replace","path":"/m~0n","value":2}]`,
+			want:  `{"m~n":2}`,
+		},
+
+		// Array indexes
+		{
+			name:  "adding at the end index appends",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"baz"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:    "adding past the end",
+			doc:     `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/2","value":"baz"}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:    "index with a leading zero",
+			doc:     `{"foo":["bar","baz"]}`,
+			patch:   `[{"op":"remove","path":"/foo/01"}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:    "removing the - index",
+			doc:     `{"foo":["bar"]}`,
+			patch:   `[{"op":"remove","path":"/foo/-"}]`,
+			wantErr: ErrNotApplicable,
+		},
+
+		// Move and copy
+		{
+			name:    "moving a value into itself",
+			doc:     `{"a":{"b":{}}}`,
+			patch:   `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:  "moving a value to its own path",
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"move","from":"/a","path":"/a"}]`,
+			want:  `{"a":{"b":1}}`,
+		},
+		{
+			name:  "copying a value into itself",
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/a/c"}]`,
+			want:  `{"a":{"b":1,"c":{"b":1}}}`,
+		},
+		{
+			name:  "changing a copy leaves its source",
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`,
+			want:  `{"a":{"b":1},"c":{"b":2}}`,
+		},
+
+		// Test on arrays and objects
+		{
+			name:  "testing an array",
+			doc:   `{"a":[1,{"b":"c"}]}`,
+			patch: `[{"op":"test","path":"/a","value":[1.0,{"b":"c"}]}]`,
+			want:  `{"a":[1,{"b":"c"}]}`,
+		},
+		{
+			name:    "testing an array in another order",
+			doc:     `{"a":[1,{"b":"c"}]}`,
+			patch:   `[{"op":"test","path":"/a","value":[{"b":"c"},1]}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:  "testing an object in another member order",
+			doc:   `{"a":{"x":1,"y":[2]}}`,
+			patch: `[{"op":"test","path":"/a","value":{"y":[2],"x":1}}]`,
+			want:  `{"a":{"x":1,"y":[2]}}`,
+		},
+		{
+			name:    "testing an object with fewer members",
+			doc:     `{"a":{"x":1,"y":2}}`,
+			patch:   `[{"op":"test","path":"/a","value":{"x":1}}]`,
+			wantErr: ErrNotApplicable,
+		},
+
+		// The whole document
+		{
+			name:  "replacing the whole document",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"","value":[1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:    "removing the whole document",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"remove","path":""}]`,
+			wantErr: ErrNotApplicable,
+		},
+		{
+			name:    "operations see the changes of earlier ones",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"remove","path":"/a"},{"op":"test","path":"/a","value":1}]`,
+			wantErr: ErrNotApplicable,
+		},
+
+		// Malformed patches
+		{
+			name:    "not an array",
+			doc:     `{}`,
+			patch:   `{"op":"add","path":"/a","value":1}`,
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "unknown op",
+			doc:     `{}`,
+			patch:   `[{"op":"append","path":"/a","value":1}]`,
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "missing value",
+			doc:     `{}`,
+			patch:   `[{"op":"add","path":"/a"}]`,
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "missing from",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"copy","path":"/b"}]`,
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "pointer without a leading slash",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"remove","path":"a"}]`,
+			wantErr: ErrMalformed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Apply error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != canonical(t, tt.want) {
+				t.Errorf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package patch applies JSON Merge Patch (RFC 7386) and JSON Patch (RFC 6902)
+// documents to JSON documents. Both take and return encoded JSON, so a
+// resource is patched by encoding it, applying the patch and decoding and
+// validating the result like any other request body.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Media types of PATCH request bodies
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrMalformed is returned for patches that are not valid JSON or not a
+	// valid list of operations
+	ErrMalformed = errors.New("malformed patch")
+
+	// ErrNotApplicable is returned when an operation cannot be applied to the
+	// document, e.g. its path does not exist or a test operation fails
+	ErrNotApplicable = errors.New("patch not applicable")
+)
+
+// Merge applies a JSON Merge Patch to doc. Members of the patch replace those
+// of the document, objects are merged recursively and null removes a member.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := decode(doc, &target); err != nil {
+		return nil, err
+	}
+	var p interface{}
+	if err := decode(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+// merge implements the MergePatch function of RFC 7386, section 2
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = merge(t[key], value)
+		}
+	}
+	return t
+}
+
+// decode decodes a single JSON value, keeping numbers as json.Number so they
+// pass through patches unchanged
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if err := dec.Decode(new(json.RawMessage)); err != io.EOF {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package response
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Option adds validators to a success response, so that clients can make
+// conditional requests against it
+type Option func(*validators)
+
+type validators struct {
+	etag         string // strong, unquoted
+	weak         bool
+	lastModified time.Time
+}
+
+// WithETag sends a strong ETag, e.g. the version of the resource. It must
+// change whenever the response data changes.
+func WithETag(tag string) Option {
+	return func(v *validators) {
+		v.etag = tag
+	}
+}
+
+// WithWeakETag sends a weak ETag hashed from the response data, for responses
+// without a version of their own such as lists
+func WithWeakETag() Option {
+	return func(v *validators) {
+		v.weak = true
+	}
+}
+
+// WithLastModified sends Last-Modified, usually the UpdatedAt of the resource
+func WithLastModified(t time.Time) Option {
+	return func(v *validators) {
+		v.lastModified = t
+	}
+}
+
+// writeValidators sets the ETag and Last-Modified headers of the response
+// and reports whether a GET or HEAD request already has the current
+// representation (RFC 9110, section 13.2.2), so 304 Not Modified can be sent
+// instead of the body.
+func writeValidators(c echo.Context, payload interface{}, opts []Option) (notModified bool, err error) {
+	if len(opts) == 0 {
+		return false, nil
+	}
+	var v validators
+	for _, opt := range opts {
+		opt(&v)
+	}
+
+	header := c.Response().Header()
+	etag := ""
+	switch {
+	case v.etag != "":
+		etag = `"` + v.etag + `"`
+	case v.weak:
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return false, err
+		}
+		sum := sha256.Sum256(b)
+		etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !v.lastModified.IsZero() {
+		header.Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+	// Responses are per caller; make browsers revalidate instead of guessing
+	// a freshness lifetime from Last-Modified
+	if header.Get(echo.HeaderCacheControl) == "" {
+		header.Set(echo.HeaderCacheControl, "private, no-cache")
+	}
+
+	req := c.Request()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false, nil
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && matchesAny(inm, etag), nil
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !v.lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !v.lastModified.Truncate(time.Second).After(since), nil
+	}
+	return false, nil
+}
+
+// matchesAny reports whether a list of entity tags such as If-None-Match holds
+// the tag, using weak comparison
+func matchesAny(list, etag string) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch returns the strong entity tags listed in the If-Match header, without
+// quotes (RFC 9110, section 13.1.1). conditional is false when the header is
+// absent or "*", so any current state may be changed. Weak tags never match
+// and are left out.
+func IfMatch(c echo.Context) (tags []string, conditional bool) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, false
+	}
+
+	tags = []string{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		tags = append(tags, tag[1:len(tag)-1])
+	}
+	return tags, true
+}
@@ -137,6 +137,12 @@ func ErrConflict(message string) *AppError {
 	return NewAppError(http.StatusConflict, "ERR_CONFLICT", message, nil, nil)
 }
 
+// ErrPreconditionFailed reports a conditional request, e.g. with If-Match,
+// whose condition no longer holds because the resource changed
+func ErrPreconditionFailed(message string) *AppError {
+	return NewAppError(http.StatusPreconditionFailed, "ERR_PRECONDITION_FAILED", message, nil, nil)
+}
+
 func ErrTooManyRequests(message string) *AppError {
 	return NewAppError(http.StatusTooManyRequests, "ERR_TOO_MANY_REQUESTS", message, nil, nil)
 }
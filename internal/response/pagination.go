@@ -0,0 +1,67 @@
+package response
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PageMeta describes a page of a list. Offset pages set Offset; cursor pages
+// set Cursor to the cursor they were requested with, if any.
+type PageMeta struct {
+	Total      int64  `json:"total"` // rows matching the filters across all pages
+	Limit      int    `json:"limit"`
+	Offset     *int   `json:"offset,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"` // whether a next page exists
+}
+
+// Paginated responds with a page of a list, its meta block and RFC 8288 Link
+// headers pointing at the first, previous and next pages. Options work as
+// with OK; WithWeakETag covers the meta block as well.
+func Paginated[T any](c echo.Context, message string, data []T, meta PageMeta, opts ...Option) error {
+	c.Response().Header().Set("Link", strings.Join(pageLinks(c, meta), ", "))
+	return respond(c, http.StatusOK, message, data, &meta, opts)
+}
+
+// pageLinks returns the Link header values of the neighbouring pages. Their
+// targets repeat the request with only the page parameters changed.
+func pageLinks(c echo.Context, meta PageMeta) []string {
+	link := func(rel string, set map[string]string) string {
+		u := *c.Request().URL
+		query := u.Query()
+		for _, param := range []string{"offset", "cursor", "after"} {
+			query.Del(param)
+		}
+		for param, value := range set {
+			query.Set(param, value)
+		}
+		u.RawQuery = query.Encode()
+		return "<" + u.RequestURI() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("first", nil)}
+	if meta.Offset == nil {
+		if meta.PrevCursor != "" {
+			links = append(links, link("prev", map[string]string{"cursor": meta.PrevCursor}))
+		}
+		if meta.NextCursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": meta.NextCursor}))
+		}
+		return links
+	}
+
+	offset := *meta.Offset
+	if offset > 0 {
+		prev := max(offset-meta.Limit, 0)
+		links = append(links, link("prev", map[string]string{"offset": strconv.Itoa(prev)}))
+	}
+	if meta.HasMore {
+		links = append(links, link("next", map[string]string{"offset": strconv.Itoa(offset + meta.Limit)}))
+	}
+	return links
+}
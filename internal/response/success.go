@@ -8,18 +8,34 @@ import (
 )
 
 type successResponse[T any] struct {
-	Success   bool   `json:"success"`
-	Timestamp string `json:"timestamp"`
-	Message   string `json:"message"`
-	RequestID string `json:"request_id"`
-	Data      T      `json:"data"`
+	Success   bool      `json:"success"`
+	Timestamp string    `json:"timestamp"`
+	Message   string    `json:"message"`
+	RequestID string    `json:"request_id"`
+	Data      T         `json:"data"`
+	Meta      *PageMeta `json:"meta,omitempty"`
 }
 
-func respondSuccess[T any](c echo.Context, status int, message string, data T) error {
+func respondSuccess[T any](c echo.Context, status int, message string, data T, opts ...Option) error {
+	return respond(c, status, message, data, nil, opts)
+}
+
+func respond[T any](c echo.Context, status int, message string, data T, meta *PageMeta, opts []Option) error {
 	if c.Response().Committed {
 		return nil
 	}
 
+	notModified, err := writeValidators(c, struct {
+		Data T         `json:"data"`
+		Meta *PageMeta `json:"meta,omitempty"`
+	}{data, meta}, opts)
+	if err != nil {
+		return err
+	}
+	if notModified && status == http.StatusOK {
+		return c.NoContent(http.StatusNotModified)
+	}
+
 	if status == http.StatusNoContent {
 		// Ensure request ID header is set for tracing consistency
 		requestID := c.Response().Header().Get(echo.HeaderXRequestID)
@@ -35,15 +51,18 @@ func respondSuccess[T any](c echo.Context, status int, message string, data T) e
 		Message:   message,
 		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
 		Data:      data,
+		Meta:      meta,
 	})
 }
 
-func OK[T any](c echo.Context, message string, data T) error {
-	return respondSuccess(c, http.StatusOK, message, data)
+// OK responds with data. Options add ETag and Last-Modified headers and answer
+// conditional GET requests for unchanged data with 304 Not Modified.
+func OK[T any](c echo.Context, message string, data T, opts ...Option) error {
+	return respondSuccess(c, http.StatusOK, message, data, opts...)
 }
 
-func Created[T any](c echo.Context, message string, data T) error {
-	return respondSuccess(c, http.StatusCreated, message, data)
+func Created[T any](c echo.Context, message string, data T, opts ...Option) error {
+	return respondSuccess(c, http.StatusCreated, message, data, opts...)
 }
 
 func Accepted[T any](c echo.Context, message string, data T) error {
@@ -38,7 +38,7 @@ func ToValidationErrors(err error) []ValidationError {
 
 func validationMessage(e validator.FieldError) string {
 	switch e.Tag() {
-	case "required":
+	case "required", "required_if", "required_unless", "required_with":
 		return "This field is required"
 	case "email":
 		return "Must be a valid email address"
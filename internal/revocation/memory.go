@@ -0,0 +1,65 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps revocations in process memory. It is intended for
+// development and single-instance deployments; entries are lost on restart.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[uuid.UUID]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[uuid.UUID]time.Time),
+	}
+}
+
+func (s *MemoryStore) RevokeToken(_ context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop entries for tokens that have expired on their own
+	now := time.Now()
+	for id, exp := range s.tokens {
+		if exp.Before(now) {
+			delete(s.tokens, id)
+		}
+	}
+
+	s.tokens[id] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) RevokeUser(_ context.Context, userID uuid.UUID, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.users[userID]; !ok || before.After(current) {
+		s.users[userID] = before
+	}
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(_ context.Context, ids []string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, id := range ids {
+		if exp, ok := s.tokens[id]; ok && exp.After(time.Now()) {
+			return true, nil
+		}
+	}
+	if cutoff, ok := s.users[userID]; ok && !issuedAt.After(cutoff) {
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,69 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRevokeUser(t *testing.T) {
+	cutoff := time.Date(2026, 1, 2, 3, 4, 5, 500_000_000, time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		revoked  bool
+	}{
+		// API keys are checked with their full precision creation time
+		{"key created earlier in the same second", cutoff.Add(-400 * time.Millisecond), true},
+		{"key created at the cutoff", cutoff, true},
+		{"key created after the cutoff", cutoff.Add(time.Millisecond), false},
+		// Access tokens only carry the second of their iat claim
+		{"token issued in the same second", cutoff.Truncate(time.Second), true},
+		{"token issued in the next second", cutoff.Truncate(time.Second).Add(time.Second), false},
+		{"token issued earlier", cutoff.Add(-time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			userID := uuid.New()
+			if err := store.RevokeUser(ctx, userID, cutoff); err != nil {
+				t.Fatal(err)
+			}
+
+			revoked, err := store.IsRevoked(ctx, []string{uuid.NewString()}, userID, tt.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.revoked {
+				t.Errorf("IsRevoked = %v, want %v", revoked, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestRevokeUserKeepsLatestCutoff(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	userID := uuid.New()
+	cutoff := time.Now()
+
+	if err := store.RevokeUser(ctx, userID, cutoff); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeUser(ctx, userID, cutoff.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := store.IsRevoked(ctx, nil, userID, cutoff.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("an earlier cutoff replaced a later one")
+	}
+}
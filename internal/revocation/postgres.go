@@ -0,0 +1,76 @@
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokedToken is a denylisted access token, or session if JTI holds a sid
+type RevokedToken struct {
+	JTI       string    `gorm:"type:varchar(64);primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TableName returns the table name for the RevokedToken model
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// UserRevocation rejects all tokens of a user issued at or before RevokedBefore
+type UserRevocation struct {
+	UserID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	RevokedBefore time.Time `gorm:"not null"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName returns the table name for the UserRevocation model
+func (UserRevocation) TableName() string {
+	return "user_revocations"
+}
+
+// PostgresStore persists revocations so they are shared by every instance.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) RevokeToken(ctx context.Context, id string, expiresAt time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Drop entries for tokens that have expired on their own
+		if err := tx.Where("expires_at <= ?", time.Now()).Delete(&RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&RevokedToken{JTI: id, ExpiresAt: expiresAt}).Error
+	})
+}
+
+func (s *PostgresStore) RevokeUser(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.Set{{
+				Column: clause.Column{Name: "revoked_before"},
+				Value:  gorm.Expr("GREATEST(user_revocations.revoked_before, EXCLUDED.revoked_before)"),
+			}},
+		}).
+		Create(&UserRevocation{UserID: userID, RevokedBefore: before}).Error
+}
+
+func (s *PostgresStore) IsRevoked(ctx context.Context, ids []string, userID uuid.UUID, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := s.db.WithContext(ctx).Raw(`
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti IN ? AND expires_at > NOW())
+		    OR EXISTS (SELECT 1 FROM user_revocations WHERE user_id = ? AND revoked_before >= ?)`,
+		ids, userID, issuedAt,
+	).Scan(&revoked).Error
+	return revoked, err
+}
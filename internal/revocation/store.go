@@ -0,0 +1,25 @@
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store records revoked access tokens by ID and per-user revocation cutoffs.
+// Token IDs are jti claims, or sid claims to revoke every token of a session.
+type Store interface {
+	// RevokeToken denylists a token ID until its tokens would have expired anyway.
+	RevokeToken(ctx context.Context, id string, expiresAt time.Time) error
+
+	// RevokeUser rejects every token of the user issued at or before the
+	// cutoff. The cutoff keeps its full precision, so with the one second
+	// precision of the iat claim tokens issued in the same second are rejected
+	// as well.
+	RevokeUser(ctx context.Context, userID uuid.UUID, before time.Time) error
+
+	// IsRevoked reports whether any of the token's IDs is denylisted or the
+	// token was issued at or before the user's cutoff.
+	IsRevoked(ctx context.Context, ids []string, userID uuid.UUID, issuedAt time.Time) (bool, error)
+}
@@ -1,17 +1,65 @@
 package server
 
 import (
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/audit"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/apikeys"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/auditlog"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/auth"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/example"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/features/roles"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/keyring"
+	appMiddleware "github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/revocation"
 )
 
 type RoutesConfig struct {
-	ExampleHandler *example.Handler
+	Tenant              config.TenantConfig
+	Audit               audit.Recorder
+	AccessKeys          *keyring.Keyring
+	Revocations         revocation.Store
+	APIKeyAuthenticator appMiddleware.APIKeyAuthenticator
+	Permissions         appMiddleware.PermissionResolver
+	ExampleHandler      *example.Handler
+	AuthHandler         *auth.Handler
+	APIKeyHandler       *apikeys.Handler
+	RoleHandler         *roles.Handler
+	AuditLogHandler     *auditlog.Handler
 }
 
 func (s *Server) RegisterRoutes(cfg RoutesConfig) {
 	api := s.Echo.Group("/api/v1")
+	jwtAuth := appMiddleware.JWTMiddleware(cfg.AccessKeys, cfg.Revocations)
+	loadPermissions := appMiddleware.LoadPermissions(cfg.Permissions)
+	resolveTenant := appMiddleware.ResolveTenant(cfg.Tenant)
+	auditCaller := appMiddleware.Audit(cfg.Audit)
+
+	// requireAuth accepts access tokens only, authenticate also accepts API keys.
+	// Both bind the request to the tenant of the authenticated caller and
+	// record the caller for audit entries.
+	requireAuth := appMiddleware.Chain(jwtAuth, resolveTenant, loadPermissions, auditCaller)
+	authenticate := appMiddleware.Chain(appMiddleware.APIKeyMiddleware(cfg.APIKeyAuthenticator, jwtAuth), resolveTenant, loadPermissions, auditCaller)
+
+	// Second login step for accounts with MFA enabled
+	requireMFAPending := appMiddleware.Chain(appMiddleware.JWTMiddleware(cfg.AccessKeys, cfg.Revocations, appMiddleware.TokenTypeMFAPending), resolveTenant)
+
+	// Auth feature routes; public routes such as register use the requested tenant
+	authGroup := api.Group("/auth", resolveTenant)
+	auth.RegisterRoutes(authGroup, cfg.AuthHandler, requireAuth, requireMFAPending)
+
+	// API key management routes
+	apiKeysGroup := api.Group("/api-keys")
+	apikeys.RegisterRoutes(apiKeysGroup, cfg.APIKeyHandler, requireAuth)
+
+	// Roles feature routes
+	rolesGroup := api.Group("/roles", requireAuth)
+	roles.RegisterRoutes(rolesGroup, cfg.RoleHandler)
+
+	// Audit log routes
+	auditGroup := api.Group("/audit", requireAuth)
+	auditlog.RegisterRoutes(auditGroup, cfg.AuditLogHandler)
 
 	// Example feature routes
-	itemsGroup := api.Group("/items")
+	itemsGroup := api.Group("/items", authenticate)
 	example.RegisterRoutes(itemsGroup, cfg.ExampleHandler)
 }
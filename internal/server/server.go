@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,6 +13,8 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/config"
+	"github.com/SuperIntelligence-Labs/go-backend-template/internal/keyring"
 	appMiddleware "github.com/SuperIntelligence-Labs/go-backend-template/internal/middleware"
 	"github.com/SuperIntelligence-Labs/go-backend-template/internal/response"
 )
@@ -19,20 +22,27 @@ import (
 // Server wraps the Echo HTTP server.
 type Server struct {
 	Echo *echo.Echo
+
+	onShutdown []func(ctx context.Context) error
 }
 
-func New() *Server {
+// New creates the Echo server and publishes the public access token keys at
+// /.well-known/jwks.json so other services can verify tokens.
+func New(cfg config.ServerConfig, accessKeys *keyring.Keyring) *Server {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 	e.HTTPErrorHandler = response.ErrorHandler
 	e.Validator = response.NewValidator()
+	e.IPExtractor = ipExtractor(cfg.TrustedProxies)
 
 	// Middleware
 	e.Use(middleware.RequestID())
 	e.Use(appMiddleware.Zerolog())
 	e.Use(middleware.Recover())
 	e.Use(middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+		// The timeout buffers whole responses, so streaming routes skip it
+		Skipper: appMiddleware.IsStreaming,
 		Timeout: 30 * time.Second,
 	}))
 
@@ -43,6 +53,13 @@ func New() *Server {
 		})
 	})
 
+	// Public keys for verifying access tokens (RFC 7517). Served without the
+	// response envelope because JWKS clients expect the raw key set.
+	e.GET("/.well-known/jwks.json", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(http.StatusOK, accessKeys.JWKS())
+	})
+
 	// Invalid route handler
 	e.Any("/*", func(c echo.Context) error {
 		return response.ErrNotFound("Route not found")
@@ -51,6 +68,34 @@ func New() *Server {
 	return &Server{Echo: e}
 }
 
+// ipExtractor determines the client IP behind c.RealIP(), which lockouts,
+// sessions and audit entries rely on. X-Forwarded-For is only honoured when
+// sent by a trusted proxy, so clients cannot choose their own IP.
+func ipExtractor(trustedProxies []string) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, cidr := range trustedProxies {
+		// Validated by the config
+		_, ipNet, _ := net.ParseCIDR(cidr)
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// OnShutdown registers fn to run during graceful shutdown, after the last
+// request has been handled, e.g. to drain background work. Functions run in
+// the order they were registered and share the shutdown deadline.
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
 // Start begins listening and handles graceful shutdown on SIGINT/SIGTERM.
 func (s *Server) Start(addr string) error {
 	go func() {
@@ -65,5 +110,10 @@ func (s *Server) Start(addr string) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	return s.Echo.Shutdown(ctx)
+
+	errs := []error{s.Echo.Shutdown(ctx)}
+	for _, fn := range s.onShutdown {
+		errs = append(errs, fn(ctx))
+	}
+	return errors.Join(errs...)
 }
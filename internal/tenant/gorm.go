@@ -0,0 +1,77 @@
+package tenant
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Column is the column holding the tenant ID in tenant scoped tables
+const Column = "tenant_id"
+
+// setting is the Postgres run-time parameter read by row-level security policies
+const setting = "app.tenant_id"
+
+// Scope is a GORM scope limiting a query to the tenant of the statement
+// context, which must be set with db.WithContext. A missing tenant fails the
+// query with ErrMissing.
+func Scope(db *gorm.DB) *gorm.DB {
+	id, ok := FromContext(db.Statement.Context)
+	if !ok {
+		_ = db.AddError(ErrMissing)
+		return db
+	}
+	return db.Where(Column+" = ?", id)
+}
+
+// Transaction runs fn in a transaction with the tenant of the db context set
+// as app.tenant_id, so row-level security policies apply to every statement.
+// The setting is local to the transaction and never leaks to pooled connections.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	id, ok := FromContext(db.Statement.Context)
+	if !ok {
+		return ErrMissing
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT set_config(?, ?, true)", setting, id).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// EnableRowLevelSecurity installs a policy restricting the table to the rows
+// of the tenant set by Transaction. It is forced for the table owner as well,
+// but superusers and roles with BYPASSRLS are never subject to it, so the
+// application must connect as an ordinary role. Safe to run on every start.
+func EnableRowLevelSecurity(db *gorm.DB, table string) error {
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", table),
+		fmt.Sprintf("ALTER TABLE %s FORCE ROW LEVEL SECURITY", table),
+		fmt.Sprintf("DROP POLICY IF EXISTS tenant_isolation ON %s", table),
+		fmt.Sprintf(
+			"CREATE POLICY tenant_isolation ON %[1]s USING (%[2]s = current_setting('%[3]s', true)) WITH CHECK (%[2]s = current_setting('%[3]s', true))",
+			table, Column, setting,
+		),
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("row-level security on %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Backfill assigns rows created before multi-tenancy to the given tenant
+func Backfill(db *gorm.DB, id string, tables ...string) error {
+	for _, table := range tables {
+		if err := db.Table(table).Where(Column+" = ''").Update(Column, id).Error; err != nil {
+			return fmt.Errorf("backfill tenant of %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// ErrMissing is returned when a tenant scoped operation runs without a tenant
+// in its context. Queries fail instead of silently spanning every tenant.
+var ErrMissing = errors.New("tenant missing from context")
+
+// Tenant IDs are DNS labels so they can be used as subdomains.
+var idPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type contextKey struct{}
+
+// Valid reports whether id is a well-formed tenant ID
+func Valid(id string) bool {
+	return idPattern.MatchString(id)
+}
+
+// WithID returns a copy of ctx carrying the tenant ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ID carried by ctx
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}